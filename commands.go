@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/config"
+	"github.com/aastashov/megalinekg_bot/internal/event"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/calendar"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/ops"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/telegram"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+func newLogger(cnf *config.Config) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cnf.Log.GetLevel()}))
+}
+
+func newMegaLineConnector(cnf *config.Config) *megaline.Connector {
+	return megaline.NewConnector(http.Client{Timeout: cnf.MegaLine.Timeout})
+}
+
+func newLoginLimits(cnf *config.Config) usecase.LoginLimits {
+	return usecase.LoginLimits{
+		MaxAttemptsPerUser:  cnf.Login.MaxAttemptsPerUser,
+		MaxAttemptsPerLogin: cnf.Login.MaxAttemptsPerLogin,
+		Window:              cnf.Login.Window,
+		Lockout:             cnf.Login.Lockout,
+	}
+}
+
+func newPaymentLinks(cnf *config.Config) []usecase.PaymentLink {
+	links := make([]usecase.PaymentLink, 0, len(cnf.Payment.Links))
+	for _, link := range cnf.Payment.Links {
+		links = append(links, usecase.PaymentLink{Name: link.Name, URL: link.URL})
+	}
+
+	return links
+}
+
+// calendarFeedURL returns the base URL of the calendar feeds, empty when the feeds are disabled.
+func calendarFeedURL(cnf *config.Config) string {
+	if cnf.Calendar.Listen == "" {
+		return ""
+	}
+
+	return cnf.Calendar.PublicURL
+}
+
+func mustOpenDatabase(logger *slog.Logger, cnf *config.Config) *storage.Storage {
+	if cnf.Database.Driver == config.DriverSQLite {
+		return storage.MustNewSQLiteDB(logger, cnf.Database.Path)
+	}
+
+	return storage.MustNewPostgresDB(logger, cnf.Database.GetConnectionString())
+}
+
+// mustAuditSalt returns audit.salt, or the salt generated on the first start and kept in the database when it is
+// not configured.
+func mustAuditSalt(ctx context.Context, cnf *config.Config, connection *storage.Storage) string {
+	if cnf.Audit.Salt != "" {
+		return cnf.Audit.Salt
+	}
+
+	generated := make([]byte, 32)
+	if _, err := rand.Read(generated); err != nil {
+		panic(fmt.Errorf("generate audit salt: %w", err))
+	}
+
+	salt, err := storage.NewSettingStorage(connection.DB).GetOrCreate(ctx, model.SettingAuditSalt, hex.EncodeToString(generated))
+	if err != nil {
+		panic(fmt.Errorf("get audit salt: %w", err))
+	}
+
+	return salt
+}
+
+func runServe(ctx context.Context, configPath string) error {
+	cnf := config.MustLoad(configPath)
+	logger := newLogger(cnf)
+
+	// Initialize database
+	connection := mustOpenDatabase(logger, cnf)
+	defer connection.MustClose()
+
+	connection.MustMigration()
+	auditSalt := mustAuditSalt(ctx, cnf, connection)
+
+	// Initialize storage
+	userStorage := storage.NewUserStorage(connection.DB)
+	accountStorage := storage.NewAccountStorage(connection.DB)
+	snapshotStorage := storage.NewSnapshotStorage(connection.DB)
+	paymentStorage := storage.NewPaymentStorage(connection.DB)
+	auditStorage := storage.NewAuditStorage(connection.DB)
+	loginAttemptStorage := storage.NewLoginAttemptStorage(connection.DB)
+	notificationStorage := storage.NewNotificationStorage(connection.DB)
+
+	// Initialize interaction with MegaLine
+	megaLineConnector := newMegaLineConnector(cnf)
+
+	// Initialize domain events, the bus is closed before the database so that the queued events are handled
+	events := event.NewBus(logger)
+	defer events.Close()
+	usecase.SubscribeMetrics(events)
+
+	// Initialize use case
+	auditUseCase := usecase.NewAuditUseCase(logger, auditSalt, cnf.Audit.Retention, auditStorage)
+	loginGuard := usecase.NewLoginGuard(logger, auditSalt, newLoginLimits(cnf), loginAttemptStorage, auditUseCase)
+	outbox := usecase.NewOutbox(notificationStorage)
+	alertUseCase := usecase.NewAlertUseCase(logger, cnf.Billing.GetLocation(), paymentStorage, outbox)
+	balanceUseCase := usecase.NewBalanceUseCase(logger, connection, userStorage, accountStorage, snapshotStorage, paymentStorage, megaLineConnector, loginGuard, auditUseCase, cnf.Billing.GetLocation(), alertUseCase, events)
+	privacyUseCase := usecase.NewPrivacyUseCase(logger, connection, userStorage, snapshotStorage, paymentStorage, notificationStorage, auditUseCase)
+	topUpUseCase := usecase.NewTopUpUseCase(cnf.Payment.QRTemplate, newPaymentLinks(cnf))
+	chartUseCase := usecase.NewChartUseCase(logger, cnf.Billing.GetLocation(), snapshotStorage, paymentStorage)
+	forecastUseCase := usecase.NewForecastUseCase(cnf.Billing.GetLocation())
+	calendarUseCase := usecase.NewCalendarUseCase(logger, auditSalt, cnf.Billing.GetLocation(), calendarFeedURL(cnf), userStorage, auditUseCase)
+
+	go auditUseCase.RunRetention(ctx)
+
+	// Initialize interaction with Telegram
+	telegramConnector := telegram.NewConnector(logger, cnf.Telegram.Token, cnf.Telegram.Admins, cnf.Billing.GetLocation(), userStorage, balanceUseCase, privacyUseCase, auditUseCase, loginGuard, topUpUseCase, calendarUseCase, chartUseCase, forecastUseCase)
+
+	notificationUseCase := usecase.NewNotificationUseCase(logger, notificationStorage, topUpUseCase, telegramConnector)
+	go notificationUseCase.RunDispatch(ctx)
+	go notificationUseCase.RunRetention(ctx)
+
+	go balanceUseCase.RunRefresh(ctx, cnf.MegaLine.RefreshInterval)
+
+	reminderUseCase := usecase.NewReminderUseCase(logger, cnf.Billing.RemindDaysBefore, cnf.Billing.GetLocation(), connection, userStorage, accountStorage, outbox)
+	go reminderUseCase.RunReminders(ctx)
+
+	// Initialize health, readiness and metrics endpoints
+	if cnf.Ops.Listen != "" {
+		opsServer := ops.NewServer(logger, cnf.Ops.Listen, cnf.Ops.PollStaleAfter, connection, telegramConnector)
+		go opsServer.Start(ctx)
+	}
+
+	// Initialize calendar feeds
+	if cnf.Calendar.Listen != "" {
+		calendarServer := calendar.NewServer(logger, cnf.Calendar.Listen, calendarUseCase)
+		go calendarServer.Start(ctx)
+	}
+
+	logger.Info("Starting Telegram bot")
+	telegramConnector.Start(ctx)
+
+	return nil
+}
+
+func runMigrate(configPath string) error {
+	cnf := config.MustLoad(configPath)
+
+	connection := mustOpenDatabase(newLogger(cnf), cnf)
+	defer connection.MustClose()
+
+	connection.MustMigration()
+
+	fmt.Println("Migrations applied")
+	return nil
+}
+
+func runCheckLogin(ctx context.Context, configPath string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected exactly one argument: <user>")
+	}
+
+	cnf := config.MustLoad(configPath)
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	result, err := newMegaLineConnector(cnf).Login(ctx, args[0], strings.TrimSpace(password))
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	if result.Status != megaline.LoginStatusSuccess {
+		return fmt.Errorf("login failed: %s", result.Status)
+	}
+
+	numbers, err := megaline.ParseAccountNumbers(result.Body)
+	if err != nil {
+		return fmt.Errorf("parse login response: %w", err)
+	}
+
+	for _, number := range numbers {
+		fmt.Println(number)
+	}
+
+	return nil
+}
+
+func runRefresh(ctx context.Context, configPath string, args []string) error {
+	flags := flag.NewFlagSet("refresh", flag.ExitOnError)
+	telegramID := flags.Int64("tg-id", 0, "Telegram ID of the user")
+	_ = flags.Parse(args)
+
+	if *telegramID == 0 {
+		return errors.New("--tg-id is required")
+	}
+
+	cnf := config.MustLoad(configPath)
+	logger := newLogger(cnf)
+
+	connection := mustOpenDatabase(logger, cnf)
+	defer connection.MustClose()
+
+	auditSalt := mustAuditSalt(ctx, cnf, connection)
+	userStorage := storage.NewUserStorage(connection.DB)
+	accountStorage := storage.NewAccountStorage(connection.DB)
+	snapshotStorage := storage.NewSnapshotStorage(connection.DB)
+	paymentStorage := storage.NewPaymentStorage(connection.DB)
+	auditUseCase := usecase.NewAuditUseCase(logger, auditSalt, cnf.Audit.Retention, storage.NewAuditStorage(connection.DB))
+	loginGuard := usecase.NewLoginGuard(logger, auditSalt, newLoginLimits(cnf), storage.NewLoginAttemptStorage(connection.DB), auditUseCase)
+	alertUseCase := usecase.NewAlertUseCase(logger, cnf.Billing.GetLocation(), paymentStorage, usecase.NewOutbox(storage.NewNotificationStorage(connection.DB)))
+
+	// The single refresh has no subscribers, the events are published to nobody
+	events := event.NewBus(logger)
+	defer events.Close()
+
+	balanceUseCase := usecase.NewBalanceUseCase(logger, connection, userStorage, accountStorage, snapshotStorage, paymentStorage, newMegaLineConnector(cnf), loginGuard, auditUseCase, cnf.Billing.GetLocation(), alertUseCase, events)
+
+	if err := balanceUseCase.UpdateBalance(ctx, *telegramID); err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
+
+	user, _, err := userStorage.GetOrCreateByTelegramID(ctx, *telegramID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	return printJSON(user.Accounts)
+}
+
+func runExport(ctx context.Context, configPath string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	telegramID := flags.Int64("tg-id", 0, "Telegram ID of the user")
+	_ = flags.Parse(args)
+
+	if *telegramID == 0 {
+		return errors.New("--tg-id is required")
+	}
+
+	cnf := config.MustLoad(configPath)
+	logger := newLogger(cnf)
+
+	connection := mustOpenDatabase(logger, cnf)
+	defer connection.MustClose()
+
+	auditSalt := mustAuditSalt(ctx, cnf, connection)
+	auditUseCase := usecase.NewAuditUseCase(logger, auditSalt, cnf.Audit.Retention, storage.NewAuditStorage(connection.DB))
+	privacyUseCase := usecase.NewPrivacyUseCase(logger, connection, storage.NewUserStorage(connection.DB), storage.NewSnapshotStorage(connection.DB), storage.NewPaymentStorage(connection.DB), storage.NewNotificationStorage(connection.DB), auditUseCase)
+
+	export, err := privacyUseCase.Export(ctx, *telegramID)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	return printJSON(export)
+}
+
+func runParse(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	timezone := flags.String("timezone", "Asia/Bishkek", "timezone of the dates on the page")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("expected exactly one argument: <file.html>")
+	}
+
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return fmt.Errorf("load timezone: %w", err)
+	}
+
+	body, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	var account model.Account
+	if err = megaline.ParseAccountDetail(body, &account, loc); err != nil {
+		fmt.Fprintf(os.Stderr, "parse account detail: %v\n", err)
+	}
+
+	return printJSON(account)
+}
+
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(value)
+}
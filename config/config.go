@@ -1,48 +1,314 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	Database Database `yaml:"database"`
-	MegaLine MegaLine `yaml:"megaline"`
-	Telegram Telegram `yaml:"telegram"`
-	Log      Log      `yaml:"log"`
+	Database Database `yaml:"database" env-prefix:"MEGALINE_DATABASE_"`
+	MegaLine MegaLine `yaml:"megaline" env-prefix:"MEGALINE_MEGALINE_"`
+	Telegram Telegram `yaml:"telegram" env-prefix:"MEGALINE_TELEGRAM_"`
+	Log      Log      `yaml:"log" env-prefix:"MEGALINE_LOG_"`
+	Ops      Ops      `yaml:"ops" env-prefix:"MEGALINE_OPS_"`
+	Audit    Audit    `yaml:"audit" env-prefix:"MEGALINE_AUDIT_"`
+	Login    Login    `yaml:"login" env-prefix:"MEGALINE_LOGIN_"`
+	Billing  Billing  `yaml:"billing" env-prefix:"MEGALINE_BILLING_"`
+	Payment  Payment  `yaml:"payment" env-prefix:"MEGALINE_PAYMENT_"`
+	Calendar Calendar `yaml:"calendar" env-prefix:"MEGALINE_CALENDAR_"`
 }
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 type Database struct {
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Name     string `yaml:"name"`
+	// Driver is either "postgres" or "sqlite".
+	Driver string `yaml:"driver" env:"DRIVER" env-default:"postgres"`
+	// Path is the SQLite database file, used only by the "sqlite" driver.
+	Path string `yaml:"path" env:"PATH"`
+	// URL is the DSN of the Postgres database, it takes precedence over the separate fields below.
+	URL      string `yaml:"url" env:"URL"`
+	User     string `yaml:"user" env:"USER"`
+	Password string `yaml:"password" env:"PASSWORD"`
+	Host     string `yaml:"host" env:"HOST"`
+	Port     int    `yaml:"port" env:"PORT"`
+	Name     string `yaml:"name" env:"NAME"`
 }
 
 // GetConnectionURL returns the Connection URL or DSN for the database
 func (db Database) GetConnectionURL() string {
+	if db.URL != "" {
+		return db.URL
+	}
+
 	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable", db.User, db.Password, db.Host, db.Port, db.Name)
 }
 
 // GetConnectionString returns the Connection String or Key-Value Connection String for the database
 func (db Database) GetConnectionString() string {
+	if db.URL != "" {
+		return db.URL
+	}
+
 	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable", db.Host, db.User, db.Password, db.Name, db.Port)
 }
 
+func (db Database) validate() []error {
+	switch db.Driver {
+	case DriverPostgres:
+	case DriverSQLite:
+		if db.Path == "" {
+			return []error{errors.New("database.path is required for the sqlite driver")}
+		}
+
+		return nil
+	default:
+		return []error{fmt.Errorf("database.driver must be one of postgres, sqlite, got %q", db.Driver)}
+	}
+
+	if db.URL != "" {
+		return nil
+	}
+
+	var errs []error
+	if db.Host == "" {
+		errs = append(errs, errors.New("database.host is required when database.url is not set"))
+	}
+
+	if db.Port <= 0 || db.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database.port must be between 1 and 65535, got %d", db.Port))
+	}
+
+	if db.User == "" {
+		errs = append(errs, errors.New("database.user is required when database.url is not set"))
+	}
+
+	if db.Name == "" {
+		errs = append(errs, errors.New("database.name is required when database.url is not set"))
+	}
+
+	return errs
+}
+
 type MegaLine struct {
-	Timeout time.Duration `yaml:"timeout"`
+	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT"`
+	// RefreshInterval is how often the balances of all the users are refreshed in the background, zero disables
+	// the background refresh.
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"REFRESH_INTERVAL" env-default:"6h"`
+}
+
+func (ml MegaLine) validate() []error {
+	var errs []error
+	if ml.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("megaline.timeout must be positive, got %s", ml.Timeout))
+	}
+
+	if ml.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("megaline.refresh_interval must not be negative, got %s", ml.RefreshInterval))
+	}
+
+	return errs
 }
 
 type Telegram struct {
-	Token string `yaml:"token"`
+	Token string `yaml:"token" env:"TOKEN"`
+	// Admins are the Telegram IDs of the users allowed to run the admin commands.
+	Admins []int64 `yaml:"admins" env:"ADMINS"`
+}
+
+func (tg Telegram) validate() []error {
+	if tg.Token == "" {
+		return []error{errors.New("telegram.token is required")}
+	}
+
+	return nil
+}
+
+type Ops struct {
+	// Listen is the address of the health, readiness and metrics HTTP server. Empty disables the server.
+	Listen string `yaml:"listen" env:"LISTEN"`
+	// PollStaleAfter is how long after the last successful Telegram poll the bot is reported as not ready.
+	PollStaleAfter time.Duration `yaml:"poll_stale_after" env:"POLL_STALE_AFTER" env-default:"3m"`
+}
+
+func (op Ops) validate() []error {
+	if op.PollStaleAfter <= 0 {
+		return []error{fmt.Errorf("ops.poll_stale_after must be positive, got %s", op.PollStaleAfter)}
+	}
+
+	return nil
+}
+
+type Audit struct {
+	// Salt is the key of the hash of the Telegram IDs in the audit log. Keep it secret and stable, changing it
+	// makes the existing records impossible to match with the users. Empty generates the salt on the first start
+	// and keeps it in the database.
+	Salt string `yaml:"salt" env:"SALT"`
+	// Retention is how long the audit events are kept, zero keeps them forever.
+	Retention time.Duration `yaml:"retention" env:"RETENTION" env-default:"8760h"`
+}
+
+func (au Audit) validate() []error {
+	if au.Retention < 0 {
+		return []error{fmt.Errorf("audit.retention must not be negative, got %s", au.Retention)}
+	}
+
+	return nil
+}
+
+// Login limits the failed MegaLine logins per Telegram user and per MegaLine login.
+type Login struct {
+	MaxAttemptsPerUser  int           `yaml:"max_attempts_per_user" env:"MAX_ATTEMPTS_PER_USER" env-default:"5"`
+	MaxAttemptsPerLogin int           `yaml:"max_attempts_per_login" env:"MAX_ATTEMPTS_PER_LOGIN" env-default:"3"`
+	Window              time.Duration `yaml:"window" env:"WINDOW" env-default:"1h"`
+	Lockout             time.Duration `yaml:"lockout" env:"LOCKOUT" env-default:"24h"`
+}
+
+func (lg Login) validate() []error {
+	var errs []error
+	if lg.MaxAttemptsPerUser <= 0 {
+		errs = append(errs, fmt.Errorf("login.max_attempts_per_user must be positive, got %d", lg.MaxAttemptsPerUser))
+	}
+
+	if lg.MaxAttemptsPerLogin <= 0 {
+		errs = append(errs, fmt.Errorf("login.max_attempts_per_login must be positive, got %d", lg.MaxAttemptsPerLogin))
+	}
+
+	if lg.Window <= 0 {
+		errs = append(errs, fmt.Errorf("login.window must be positive, got %s", lg.Window))
+	}
+
+	if lg.Lockout <= 0 {
+		errs = append(errs, fmt.Errorf("login.lockout must be positive, got %s", lg.Lockout))
+	}
+
+	return errs
+}
+
+// Billing describes the billing periods of the personal cabinet and the reminders about them.
+type Billing struct {
+	// Timezone is the IANA name of the location the dates of the personal cabinet are in.
+	Timezone string `yaml:"timezone" env:"TIMEZONE" env-default:"Asia/Bishkek"`
+	// RemindDaysBefore is how many days before the end of the period the payment reminder is sent, zero disables
+	// the reminders.
+	RemindDaysBefore int `yaml:"remind_days_before" env:"REMIND_DAYS_BEFORE" env-default:"3"`
+}
+
+// GetLocation returns the location of the timezone, UTC if it is unknown.
+func (bl Billing) GetLocation() *time.Location {
+	loc, err := time.LoadLocation(bl.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+func (bl Billing) validate() []error {
+	var errs []error
+	if _, err := time.LoadLocation(bl.Timezone); err != nil || bl.Timezone == "" {
+		errs = append(errs, fmt.Errorf("billing.timezone must be a known IANA timezone, got %q", bl.Timezone))
+	}
+
+	if bl.RemindDaysBefore < 0 {
+		errs = append(errs, fmt.Errorf("billing.remind_days_before must not be negative, got %d", bl.RemindDaysBefore))
+	}
+
+	return errs
+}
+
+// Payment describes the top-ups offered with the reminders and the /pay command. In the templates, {account} and
+// {amount} are replaced with the account number and the amount due in soms, e.g. "150.50".
+type Payment struct {
+	// QRTemplate is the content of the QR code. The default is the plain text any scanner shows, the bank apps of
+	// Kyrgyzstan don't recognize it as a payment. For a QR code the apps pay with, set the URL of the payment page
+	// the provider issues, the values are escaped in it like in the links.
+	QRTemplate string `yaml:"qr_template" env:"QR_TEMPLATE" env-default:"MegaLine {account} {amount} KGS"`
+	// Links are the payment providers offered as buttons.
+	Links PaymentLinks `yaml:"links" env:"LINKS"`
+}
+
+type PaymentLink struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+// PaymentLinks are the payment providers. In the environment they are the name=url pairs separated by ";", e.g.
+// "Provider=https://pay.example.kg/megaline?account={account}&amount={amount}", the URL is everything after the
+// first "=".
+type PaymentLinks []PaymentLink
+
+// SetValue parses the links from the environment variable.
+func (links *PaymentLinks) SetValue(value string) error {
+	parsed := PaymentLinks{}
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, linkURL, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("payment link %q must be name=url", pair)
+		}
+
+		parsed = append(parsed, PaymentLink{Name: strings.TrimSpace(name), URL: strings.TrimSpace(linkURL)})
+	}
+
+	*links = parsed
+	return nil
+}
+
+func (pm Payment) validate() []error {
+	var errs []error
+	if !strings.Contains(pm.QRTemplate, "{account}") {
+		errs = append(errs, errors.New("payment.qr_template must contain {account}"))
+	}
+
+	for i, link := range pm.Links {
+		if link.Name == "" {
+			errs = append(errs, fmt.Errorf("payment.links[%d].name is required", i))
+		}
+
+		if parsed, err := url.Parse(link.URL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http" && parsed.Scheme != "tg") {
+			errs = append(errs, fmt.Errorf("payment.links[%d].url must be an http, https or tg URL, got %q", i, link.URL))
+		}
+	}
+
+	return errs
+}
+
+type Calendar struct {
+	// Listen is the address of the HTTP server of the calendar feeds. Empty disables the feeds, /calendar still
+	// sends the calendar as a document.
+	Listen string `yaml:"listen" env:"LISTEN"`
+	// PublicURL is the address the calendar server is reachable at from the calendar apps, e.g.
+	// "https://bot.example.kg", the feed URLs are built from it.
+	PublicURL string `yaml:"public_url" env:"PUBLIC_URL"`
+}
+
+func (cl Calendar) validate() []error {
+	if cl.Listen == "" {
+		return nil
+	}
+
+	if parsed, err := url.Parse(cl.PublicURL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return []error{fmt.Errorf("calendar.public_url must be an http or https URL when calendar.listen is set, got %q", cl.PublicURL)}
+	}
+
+	return nil
 }
 
 type Log struct {
-	Level string `yaml:"level"`
+	Level string `yaml:"level" env:"LEVEL"`
 }
 
 func (lg Log) GetLevel() slog.Level {
@@ -60,12 +326,54 @@ func (lg Log) GetLevel() slog.Level {
 	}
 }
 
+func (lg Log) validate() []error {
+	switch lg.Level {
+	case "", "debug", "info", "warn", "error":
+		return nil
+	default:
+		return []error{fmt.Errorf("log.level must be one of debug, info, warn, error, got %q", lg.Level)}
+	}
+}
+
+// Validate checks the whole config and reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.MegaLine.validate()...)
+	errs = append(errs, c.Telegram.validate()...)
+	errs = append(errs, c.Log.validate()...)
+	errs = append(errs, c.Ops.validate()...)
+	errs = append(errs, c.Audit.validate()...)
+	errs = append(errs, c.Login.validate()...)
+	errs = append(errs, c.Billing.validate()...)
+	errs = append(errs, c.Payment.validate()...)
+	errs = append(errs, c.Calendar.validate()...)
+
+	return errors.Join(errs...)
+}
+
+// MustLoad reads the config from the YAML file, applies the environment variable overrides and validates it.
+// Every field can be overridden by the variable named after its path, e.g. MEGALINE_TELEGRAM_TOKEN for
+// telegram.token, or by the path to a file with the value in the _FILE variant, e.g. MEGALINE_TELEGRAM_TOKEN_FILE.
+// The file is optional when the whole config is provided through the environment.
 func MustLoad(configPath string) *Config {
 	cfg := &Config{}
 
-	if err := cleanenv.ReadConfig(configPath, cfg); err != nil {
+	if err := loadFileEnv(cfg); err != nil {
+		panic(fmt.Errorf("cannot read config secrets: %w", err))
+	}
+
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		if err = cleanenv.ReadEnv(cfg); err != nil {
+			panic(fmt.Errorf("cannot read config from environment: %w", err))
+		}
+	} else if err = cleanenv.ReadConfig(configPath, cfg); err != nil {
 		panic(fmt.Errorf("cannot read config: %w", err))
 	}
 
+	if err := cfg.Validate(); err != nil {
+		panic(fmt.Errorf("invalid config:\n%w", err))
+	}
+
 	return cfg
 }
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+database:
+  driver: sqlite
+  path: ./megaline.db
+megaline:
+  timeout: 10s
+telegram:
+  token: "file-token"
+audit:
+  salt: "file-salt"
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+
+	return path
+}
+
+// unsetEnv unsets the variable for the test, loadFileEnv sets it with os.Setenv and t.Setenv restores it afterwards.
+func unsetEnv(t *testing.T, name string) {
+	t.Helper()
+
+	t.Setenv(name, "")
+	if err := os.Unsetenv(name); err != nil {
+		t.Fatalf("unset %s: %v", name, err)
+	}
+}
+
+func TestMustLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		files     map[string]string
+		wantToken string
+		wantSalt  string
+	}{
+		{
+			name:      "file only",
+			wantToken: "file-token",
+			wantSalt:  "file-salt",
+		},
+		{
+			name:      "env overrides file",
+			env:       map[string]string{"MEGALINE_TELEGRAM_TOKEN": "env-token"},
+			wantToken: "env-token",
+			wantSalt:  "file-salt",
+		},
+		{
+			name:      "_FILE overrides file",
+			files:     map[string]string{"MEGALINE_TELEGRAM_TOKEN_FILE": "secret-token\n"},
+			wantToken: "secret-token",
+			wantSalt:  "file-salt",
+		},
+		{
+			name:      "env takes precedence over _FILE",
+			env:       map[string]string{"MEGALINE_TELEGRAM_TOKEN": "env-token"},
+			files:     map[string]string{"MEGALINE_TELEGRAM_TOKEN_FILE": "secret-token"},
+			wantToken: "env-token",
+			wantSalt:  "file-salt",
+		},
+		{
+			name:      "_FILE of a nested section",
+			files:     map[string]string{"MEGALINE_AUDIT_SALT_FILE": "secret-salt\r\n"},
+			wantToken: "file-token",
+			wantSalt:  "secret-salt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := writeFile(t, "config.yml", testConfig)
+
+			for name, content := range tt.files {
+				t.Setenv(name, writeFile(t, "secret", content))
+				unsetEnv(t, strings.TrimSuffix(name, fileEnvSuffix))
+			}
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg := MustLoad(configPath)
+			if cfg.Telegram.Token != tt.wantToken {
+				t.Errorf("telegram.token = %q, want %q", cfg.Telegram.Token, tt.wantToken)
+			}
+
+			if cfg.Audit.Salt != tt.wantSalt {
+				t.Errorf("audit.salt = %q, want %q", cfg.Audit.Salt, tt.wantSalt)
+			}
+		})
+	}
+}
+
+func TestMustLoadWithoutFile(t *testing.T) {
+	t.Setenv("MEGALINE_DATABASE_DRIVER", DriverSQLite)
+	t.Setenv("MEGALINE_DATABASE_PATH", "./megaline.db")
+	t.Setenv("MEGALINE_MEGALINE_TIMEOUT", "5s")
+	t.Setenv("MEGALINE_TELEGRAM_TOKEN", "env-token")
+	t.Setenv("MEGALINE_AUDIT_SALT", "env-salt")
+
+	cfg := MustLoad(filepath.Join(t.TempDir(), "missing.yml"))
+	if cfg.Telegram.Token != "env-token" || cfg.MegaLine.Timeout != 5*time.Second {
+		t.Errorf("config = %+v, want the values from the environment", cfg)
+	}
+
+	if cfg.Ops.PollStaleAfter != 3*time.Minute {
+		t.Errorf("ops.poll_stale_after = %s, want the default 3m", cfg.Ops.PollStaleAfter)
+	}
+}
+
+func TestLoadFileEnvMissingFile(t *testing.T) {
+	unsetEnv(t, "MEGALINE_TELEGRAM_TOKEN")
+	t.Setenv("MEGALINE_TELEGRAM_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	err := loadFileEnv(&Config{})
+	if err == nil || !strings.Contains(err.Error(), "MEGALINE_TELEGRAM_TOKEN_FILE") {
+		t.Fatalf("loadFileEnv() error = %v, want the error naming the _FILE variable", err)
+	}
+}
+
+func TestMustLoadPaymentLinks(t *testing.T) {
+	const linksConfig = testConfig + `
+payment:
+  links:
+    - name: "File provider"
+      url: "https://file.example.kg/?account={account}"
+`
+
+	tests := []struct {
+		name string
+		env  string
+		want PaymentLinks
+	}{
+		{
+			name: "file",
+			want: PaymentLinks{{Name: "File provider", URL: "https://file.example.kg/?account={account}"}},
+		},
+		{
+			name: "env overrides file",
+			env:  "Pay=https://pay.example.kg/?account={account}&amount={amount}; Bot = tg://resolve?domain=pay ;",
+			want: PaymentLinks{
+				{Name: "Pay", URL: "https://pay.example.kg/?account={account}&amount={amount}"},
+				{Name: "Bot", URL: "tg://resolve?domain=pay"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("MEGALINE_PAYMENT_LINKS", tt.env)
+			}
+
+			cfg := MustLoad(writeFile(t, "config.yml", linksConfig))
+			if len(cfg.Payment.Links) != len(tt.want) {
+				t.Fatalf("payment.links = %+v, want %+v", cfg.Payment.Links, tt.want)
+			}
+
+			for i := range tt.want {
+				if cfg.Payment.Links[i] != tt.want[i] {
+					t.Errorf("payment.links[%d] = %+v, want %+v", i, cfg.Payment.Links[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPaymentLinksSetValue(t *testing.T) {
+	var links PaymentLinks
+	if err := links.SetValue("Pay=https://pay.example.kg;broken"); err == nil || !strings.Contains(err.Error(), `"broken"`) {
+		t.Errorf("SetValue() error = %v, want the error naming the pair without the URL", err)
+	}
+
+	if err := links.SetValue(""); err != nil || len(links) != 0 {
+		t.Errorf("SetValue(\"\") = %+v, %v, want no links", links, err)
+	}
+}
+
+func validConfig() Config {
+	return Config{
+		Database: Database{Driver: DriverPostgres, Host: "localhost", Port: 5432, User: "postgres", Name: "megaline"},
+		MegaLine: MegaLine{Timeout: 10 * time.Second, RefreshInterval: 6 * time.Hour},
+		Telegram: Telegram{Token: "token"},
+		Ops:      Ops{PollStaleAfter: 3 * time.Minute},
+		Audit:    Audit{Salt: "salt", Retention: 8760 * time.Hour},
+		Login:    Login{MaxAttemptsPerUser: 5, MaxAttemptsPerLogin: 3, Window: time.Hour, Lockout: 24 * time.Hour},
+		Billing:  Billing{Timezone: "Asia/Bishkek", RemindDaysBefore: 3},
+		Payment:  Payment{QRTemplate: "MegaLine {account} {amount} KGS"},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name: "database url replaces the separate fields",
+			modify: func(cfg *Config) {
+				cfg.Database = Database{Driver: DriverPostgres, URL: "postgres://localhost/megaline"}
+			},
+		},
+		{
+			name:   "audit salt is generated when empty",
+			modify: func(cfg *Config) { cfg.Audit.Salt = "" },
+		},
+		{
+			name:   "sqlite without path",
+			modify: func(cfg *Config) { cfg.Database = Database{Driver: DriverSQLite} },
+			want:   []string{"database.path is required for the sqlite driver"},
+		},
+		{
+			name:   "unknown driver",
+			modify: func(cfg *Config) { cfg.Database.Driver = "mysql" },
+			want:   []string{`database.driver must be one of postgres, sqlite, got "mysql"`},
+		},
+		{
+			name:   "postgres without url and fields",
+			modify: func(cfg *Config) { cfg.Database = Database{Driver: DriverPostgres} },
+			want: []string{
+				"database.host is required",
+				"database.port must be between 1 and 65535, got 0",
+				"database.user is required",
+				"database.name is required",
+			},
+		},
+		{
+			name:   "missing token",
+			modify: func(cfg *Config) { cfg.Telegram.Token = "" },
+			want:   []string{"telegram.token is required"},
+		},
+		{
+			name:   "unknown log level",
+			modify: func(cfg *Config) { cfg.Log.Level = "verbose" },
+			want:   []string{`log.level must be one of debug, info, warn, error, got "verbose"`},
+		},
+		{
+			name:   "unknown timezone",
+			modify: func(cfg *Config) { cfg.Billing.Timezone = "Mars/Olympus" },
+			want:   []string{`billing.timezone must be a known IANA timezone, got "Mars/Olympus"`},
+		},
+		{
+			name: "every invalid field is reported",
+			modify: func(cfg *Config) {
+				cfg.MegaLine.Timeout = 0
+				cfg.Ops.PollStaleAfter = -time.Second
+				cfg.Login.Window = 0
+			},
+			want: []string{
+				"megaline.timeout must be positive, got 0s",
+				"ops.poll_stale_after must be positive, got -1s",
+				"login.window must be positive, got 0s",
+			},
+		},
+		{
+			name: "payment link without scheme",
+			modify: func(cfg *Config) {
+				cfg.Payment.Links = []PaymentLink{{Name: "Provider", URL: "pay.example.kg"}}
+			},
+			want: []string{`payment.links[0].url must be an http, https or tg URL, got "pay.example.kg"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate() error = nil, want %q", tt.want)
+			}
+
+			if got := strings.Count(err.Error(), "\n") + 1; got != len(tt.want) {
+				t.Errorf("Validate() reported %d errors, want %d: %v", got, len(tt.want), err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+const fileEnvSuffix = "_FILE"
+
+// loadFileEnv sets every environment variable of the config that is not set yet from the file referenced by its
+// _FILE variant, so that the secrets can be mounted as files, e.g. as Docker secrets.
+func loadFileEnv(cfg *Config) error {
+	for _, name := range envNames(reflect.TypeOf(*cfg), "") {
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		path, ok := os.LookupEnv(name + fileEnvSuffix)
+		if !ok {
+			continue
+		}
+
+		value, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name+fileEnvSuffix, err)
+		}
+
+		if err = os.Setenv(name, strings.TrimRight(string(value), "\r\n")); err != nil {
+			return fmt.Errorf("set %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// envNames returns the names of the environment variables of the config, following the same tags as cleanenv.
+func envNames(typ reflect.Type, prefix string) []string {
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			names = append(names, envNames(field.Type, prefix+field.Tag.Get(cleanenv.TagEnvPrefix))...)
+			continue
+		}
+
+		if env := field.Tag.Get(cleanenv.TagEnv); env != "" {
+			for _, name := range strings.Split(env, cleanenv.DefaultSeparator) {
+				names = append(names, prefix+name)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,280 @@
+// Package chart renders the balance charts as PNG images without any external service.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width        = 1000
+	height       = 500
+	marginLeft   = 90
+	marginRight  = 20
+	marginTop    = 40
+	marginBottom = 40
+	yTicks       = 5
+	xTicks       = 6
+)
+
+// ErrNoData is returned when there are no points to draw.
+var ErrNoData = errors.New("no data")
+
+var (
+	colorBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	colorGrid       = color.RGBA{R: 230, G: 230, B: 230, A: 255}
+	colorAxis       = color.RGBA{R: 120, G: 120, B: 120, A: 255}
+	colorText       = color.RGBA{R: 40, G: 40, B: 40, A: 255}
+	colorBalance    = color.RGBA{R: 33, G: 102, B: 172, A: 255}
+	colorZero       = color.RGBA{R: 214, G: 39, B: 40, A: 255}
+	colorPayment    = color.RGBA{R: 44, G: 160, B: 44, A: 255}
+	colorBoundary   = color.RGBA{R: 148, G: 103, B: 189, A: 255}
+)
+
+// Point is a value at a moment, the values are in the units of the chart, e.g. soms.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// BalanceChart is the balance of an account over time with the events marked on it.
+type BalanceChart struct {
+	// Title is drawn with an ASCII font, the other characters are not shown.
+	Title    string
+	Balance  []Point
+	Payments []time.Time
+	// Boundaries are the starts of the billing periods.
+	Boundaries []time.Time
+	// Location is the location the dates are labeled in.
+	Location *time.Location
+}
+
+// Render draws the chart as a PNG image: the balance as a line, the payments as green and the period boundaries as
+// dashed purple vertical lines, and the zero balance as a red line.
+func Render(chart BalanceChart) ([]byte, error) {
+	if len(chart.Balance) == 0 {
+		return nil, ErrNoData
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: colorBackground}, image.Point{}, draw.Src)
+
+	plot := newPlot(chart)
+
+	// Grid with the value labels
+	for i := 0; i <= yTicks; i++ {
+		value := plot.minValue + (plot.maxValue-plot.minValue)*float64(i)/yTicks
+		y := plot.y(value)
+		drawLine(img, marginLeft, y, width-marginRight, y, 1, colorGrid, 0)
+		drawText(img, marginLeft-8-textWidth(formatValue(value)), y+4, formatValue(value), colorText)
+	}
+
+	// Time labels
+	for i := 0; i <= xTicks; i++ {
+		moment := plot.from.Add(time.Duration(float64(plot.to.Sub(plot.from)) * float64(i) / xTicks))
+		label := moment.In(chart.Location).Format("02.01.06")
+		x := plot.x(moment)
+		drawLine(img, x, height-marginBottom, x, height-marginBottom+4, 1, colorAxis, 0)
+		drawText(img, min(x-textWidth(label)/2, width-textWidth(label)-2), height-marginBottom+18, label, colorText)
+	}
+
+	for _, boundary := range chart.Boundaries {
+		if plot.contains(boundary) {
+			x := plot.x(boundary)
+			drawLine(img, x, marginTop, x, height-marginBottom, 1, colorBoundary, 6)
+		}
+	}
+
+	for _, payment := range chart.Payments {
+		if plot.contains(payment) {
+			x := plot.x(payment)
+			drawLine(img, x, marginTop, x, height-marginBottom, 2, colorPayment, 0)
+			fillCircle(img, x, marginTop, 5, colorPayment)
+		}
+	}
+
+	if plot.minValue < 0 && plot.maxValue > 0 {
+		y := plot.y(0)
+		drawLine(img, marginLeft, y, width-marginRight, y, 1, colorZero, 0)
+	}
+
+	// Axes
+	drawLine(img, marginLeft, marginTop, marginLeft, height-marginBottom, 1, colorAxis, 0)
+	drawLine(img, marginLeft, height-marginBottom, width-marginRight, height-marginBottom, 1, colorAxis, 0)
+
+	for i, point := range chart.Balance {
+		x, y := plot.x(point.Time), plot.y(point.Value)
+		if i > 0 {
+			previous := chart.Balance[i-1]
+			drawLine(img, plot.x(previous.Time), plot.y(previous.Value), x, y, 2, colorBalance, 0)
+		}
+
+		fillCircle(img, x, y, 3, colorBalance)
+	}
+
+	drawText(img, marginLeft, marginTop-16, chart.Title, colorText)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// plot maps the times and the values to the pixels of the plot area.
+type plot struct {
+	from, to           time.Time
+	minValue, maxValue float64
+}
+
+func newPlot(chart BalanceChart) plot {
+	p := plot{
+		from:     chart.Balance[0].Time,
+		to:       chart.Balance[len(chart.Balance)-1].Time,
+		minValue: math.Inf(1),
+		maxValue: math.Inf(-1),
+	}
+
+	for _, point := range chart.Balance {
+		p.minValue = math.Min(p.minValue, point.Value)
+		p.maxValue = math.Max(p.maxValue, point.Value)
+	}
+
+	// A single snapshot or a flat balance still gets a visible range
+	if !p.to.After(p.from) {
+		p.from, p.to = p.from.Add(-12*time.Hour), p.to.Add(12*time.Hour)
+	}
+
+	padding := (p.maxValue - p.minValue) * 0.1
+	if padding == 0 {
+		padding = math.Max(math.Abs(p.maxValue)*0.1, 1)
+	}
+
+	p.minValue, p.maxValue = p.minValue-padding, p.maxValue+padding
+	return p
+}
+
+func (p plot) contains(moment time.Time) bool {
+	return !moment.Before(p.from) && !moment.After(p.to)
+}
+
+func (p plot) x(moment time.Time) int {
+	ratio := float64(moment.Sub(p.from)) / float64(p.to.Sub(p.from))
+	return marginLeft + int(math.Round(ratio*float64(width-marginLeft-marginRight)))
+}
+
+func (p plot) y(value float64) int {
+	ratio := (value - p.minValue) / (p.maxValue - p.minValue)
+	return height - marginBottom - int(math.Round(ratio*float64(height-marginTop-marginBottom)))
+}
+
+// drawLine draws the line of the thickness with Bresenham's algorithm, a positive dash draws it dashed.
+func drawLine(img *image.RGBA, x0, y0, x1, y1, thickness int, c color.Color, dash int) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+
+	for step := 0; ; step++ {
+		if dash <= 0 || (step/dash)%2 == 0 {
+			fillRect(img, x0-thickness/2, y0-thickness/2, thickness, thickness, c)
+		}
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.Color) {
+	draw.Draw(img, image.Rect(x, y, x+w, y+h), &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r int, c color.Color) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				img.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+func drawText(img *image.RGBA, x, y int, text string, c color.Color) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+
+	drawer.DrawString(text)
+}
+
+func textWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Round()
+}
+
+// formatValue formats the value for the axis, e.g. "-1 250".
+func formatValue(value float64) string {
+	rounded := int64(math.Round(value))
+	text := fmt.Sprint(abs64(rounded))
+	for i := len(text) - 3; i > 0; i -= 3 {
+		text = text[:i] + " " + text[i:]
+	}
+
+	if rounded < 0 {
+		return "-" + text
+	}
+
+	return text
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,128 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+)
+
+// queueSize is the number of the events waiting for a subscriber before the new ones are dropped.
+const queueSize = 64
+
+// Bus delivers the published events to the subscribers of their type. Every subscriber has its own queue and
+// goroutine, so a slow or panicking subscriber does not hold up or break the others, and gets the events in the
+// order they were published. Publish never blocks, the events that don't fit into the full queue of a slow
+// subscriber are dropped, so the bus is only for what may be lost.
+type Bus struct {
+	logger *slog.Logger
+
+	mu            sync.RWMutex
+	closed        bool
+	subscriptions map[string][]*subscription
+	wg            sync.WaitGroup
+}
+
+type subscription struct {
+	name    string
+	handler func(ctx context.Context, event Event)
+	queue   chan delivery
+}
+
+type delivery struct {
+	ctx   context.Context
+	event Event
+}
+
+func NewBus(logger *slog.Logger) *Bus {
+	return &Bus{
+		logger:        logger.With("component", "event_bus"),
+		subscriptions: make(map[string][]*subscription),
+	}
+}
+
+// Subscribe registers the handler of the events of type E under the subscriber name used in the logs and metrics.
+func Subscribe[E Event](bus *Bus, subscriber string, handler func(ctx context.Context, event E)) {
+	var zero E
+	bus.subscribe(zero.Name(), subscriber, func(ctx context.Context, event Event) {
+		handler(ctx, event.(E))
+	})
+}
+
+func (b *Bus) subscribe(eventName, subscriber string, handler func(ctx context.Context, event Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &subscription{name: subscriber, handler: handler, queue: make(chan delivery, queueSize)}
+	b.subscriptions[eventName] = append(b.subscriptions[eventName], sub)
+
+	b.wg.Add(1)
+	go b.run(eventName, sub)
+}
+
+// Publish queues the event for every subscriber of its type without waiting, the event is dropped for the
+// subscribers with the full queue. The handlers get the values of the context but not its cancellation, the event
+// has happened and is handled even if the request publishing it is over.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		b.logger.Warn("event published after close", "event", event.Name())
+		return
+	}
+
+	for _, sub := range b.subscriptions[event.Name()] {
+		select {
+		case sub.queue <- delivery{ctx: context.WithoutCancel(ctx), event: event}:
+		default:
+			metrics.EventsDropped.WithLabelValues(event.Name(), sub.name).Inc()
+			b.logger.Warn("event dropped, subscriber queue is full", "event", event.Name(), "subscriber", sub.name)
+		}
+	}
+}
+
+// Close stops accepting the events and waits for the subscribers to handle the queued ones.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+
+	b.closed = true
+	for _, subs := range b.subscriptions {
+		for _, sub := range subs {
+			close(sub.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *Bus) run(eventName string, sub *subscription) {
+	defer b.wg.Done()
+
+	for d := range sub.queue {
+		result := b.handle(eventName, sub, d)
+		metrics.EventsHandled.WithLabelValues(eventName, sub.name, result).Inc()
+	}
+}
+
+// handle calls the handler recovering from its panic, it returns the result for the metrics.
+func (b *Bus) handle(eventName string, sub *subscription, d delivery) (result string) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Error("event handler panicked", "event", eventName, "subscriber", sub.name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			result = "panic"
+		}
+	}()
+
+	sub.handler(d.ctx, d.event)
+
+	return "success"
+}
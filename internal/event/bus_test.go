@@ -0,0 +1,198 @@
+package event
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	N int
+}
+
+func (testEvent) Name() string { return "test" }
+
+type otherEvent struct{}
+
+func (otherEvent) Name() string { return "other" }
+
+func newTestBus() *Bus {
+	return NewBus(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// recorder collects the events handled by a subscriber.
+type recorder struct {
+	mu     sync.Mutex
+	events []int
+}
+
+func (r *recorder) handle(_ context.Context, e testEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, e.N)
+}
+
+func (r *recorder) handled() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]int(nil), r.events...)
+}
+
+func TestBusDeliversInOrder(t *testing.T) {
+	bus := newTestBus()
+
+	first, second := &recorder{}, &recorder{}
+	Subscribe(bus, "first", first.handle)
+	Subscribe(bus, "second", second.handle)
+	Subscribe(bus, "other", func(context.Context, otherEvent) { t.Error("other subscriber got the test event") })
+
+	for i := range 10 {
+		bus.Publish(context.Background(), testEvent{N: i})
+	}
+
+	bus.Close()
+
+	for name, r := range map[string]*recorder{"first": first, "second": second} {
+		got := r.handled()
+		if len(got) != 10 {
+			t.Fatalf("%s handled %v, want 10 events", name, got)
+		}
+
+		for i, n := range got {
+			if n != i {
+				t.Errorf("%s handled %v, want the publishing order", name, got)
+				break
+			}
+		}
+	}
+}
+
+func TestBusIsolatesPanics(t *testing.T) {
+	bus := newTestBus()
+
+	healthy := &recorder{}
+	Subscribe(bus, "panicking", func(_ context.Context, e testEvent) {
+		if e.N%2 == 0 {
+			panic("boom")
+		}
+	})
+	Subscribe(bus, "healthy", healthy.handle)
+
+	panickingAfter := &recorder{}
+	Subscribe(bus, "panicking_after", func(ctx context.Context, e testEvent) {
+		panickingAfter.handle(ctx, e)
+		if e.N == 0 {
+			panic("boom")
+		}
+	})
+
+	for i := range 3 {
+		bus.Publish(context.Background(), testEvent{N: i})
+	}
+
+	bus.Close()
+
+	if got := healthy.handled(); len(got) != 3 {
+		t.Errorf("healthy subscriber handled %v, want 3 events", got)
+	}
+
+	if got := panickingAfter.handled(); len(got) != 3 {
+		t.Errorf("panicking subscriber handled %v, want the events after the panic as well", got)
+	}
+}
+
+func TestBusCloseDrainsQueue(t *testing.T) {
+	bus := newTestBus()
+
+	release := make(chan struct{})
+	r := &recorder{}
+	Subscribe(bus, "slow", func(ctx context.Context, e testEvent) {
+		<-release
+		r.handle(ctx, e)
+	})
+
+	for i := range 5 {
+		bus.Publish(context.Background(), testEvent{N: i})
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the queued events were handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-closed
+
+	if got := r.handled(); len(got) != 5 {
+		t.Errorf("handled %v, want all 5 queued events", got)
+	}
+
+	bus.Publish(context.Background(), testEvent{N: 5})
+	bus.Close()
+
+	if got := r.handled(); len(got) != 5 {
+		t.Errorf("handled %v after close, want the event published after close ignored", got)
+	}
+}
+
+func TestBusPublishDoesNotBlock(t *testing.T) {
+	bus := newTestBus()
+
+	release := make(chan struct{})
+	r := &recorder{}
+	Subscribe(bus, "stuck", func(ctx context.Context, e testEvent) {
+		<-release
+		r.handle(ctx, e)
+	})
+
+	published := make(chan struct{})
+	go func() {
+		for i := range queueSize * 2 {
+			bus.Publish(context.Background(), testEvent{N: i})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on the full queue")
+	}
+
+	close(release)
+	bus.Close()
+
+	if got := len(r.handled()); got < queueSize || got > queueSize+1 {
+		t.Errorf("handled %d events, want the %d queued and maybe the one in progress", got, queueSize)
+	}
+}
+
+func TestBusPublishedContextIsNotCanceled(t *testing.T) {
+	bus := newTestBus()
+
+	errs := make(chan error, 1)
+	Subscribe(bus, "ctx", func(ctx context.Context, _ testEvent) {
+		errs <- ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bus.Publish(ctx, testEvent{})
+	cancel()
+	bus.Close()
+
+	if err := <-errs; err != nil {
+		t.Errorf("handler context error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Package event is the in-process bus of the domain events observed by the balance refresh. The use cases publish
+// the events after saving what they observed, the subscribers react to them without the publisher knowing them.
+package event
+
+import "github.com/aastashov/megalinekg_bot/internal/model"
+
+// Event is a domain event, the name identifies its type on the bus.
+type Event interface {
+	Name() string
+}
+
+// AccountDiscovered is published when a login lists an account the user did not have before.
+type AccountDiscovered struct {
+	UserID  int64
+	Account model.Account
+}
+
+// BalanceChanged is published when the balance differs from the previous snapshot or new payments arrived since.
+// Previous is nil for the first snapshot of the account.
+type BalanceChanged struct {
+	UserID   int64
+	Account  model.Account
+	Previous *model.BalanceSnapshot
+	Current  model.BalanceSnapshot
+}
+
+// PaymentDetected is published for every payment stored for the first time.
+type PaymentDetected struct {
+	UserID  int64
+	Account model.Account
+	Payment model.Payment
+}
+
+// PeriodRolledOver is published when the billing period of the account differs from the previous one.
+type PeriodRolledOver struct {
+	UserID  int64
+	Account model.Account
+	// Previous is the period that has ended and PreviousTariff is the tariff charged for it.
+	Previous       model.BillingPeriod
+	PreviousTariff model.Money
+	// PreviousSnapshot is the snapshot before the rollover, nil for the first snapshot of the account, and Current
+	// is the snapshot that observed the rollover.
+	PreviousSnapshot *model.BalanceSnapshot
+	Current          model.BalanceSnapshot
+}
+
+// StatusChanged is published when the status of the account differs from the previous one. The first status seen
+// is not a change.
+type StatusChanged struct {
+	UserID   int64
+	Account  model.Account
+	Previous model.AccountStatus
+	// SnapshotID is the snapshot of the refresh that observed the change.
+	SnapshotID int
+}
+
+// TariffChanged is published when the tariff amount or name of the account differs from the previous one.
+type TariffChanged struct {
+	UserID         int64
+	Account        model.Account
+	PreviousAmount model.Money
+	PreviousName   string
+	// SnapshotID is the snapshot of the refresh that observed the change.
+	SnapshotID int
+}
+
+// LoginFailed is published when the login to the personal cabinet fails, the reason is the one audited.
+type LoginFailed struct {
+	UserID int64
+	Reason string
+}
+
+func (AccountDiscovered) Name() string { return "account_discovered" }
+func (BalanceChanged) Name() string    { return "balance_changed" }
+func (PaymentDetected) Name() string   { return "payment_detected" }
+func (PeriodRolledOver) Name() string  { return "period_rolled_over" }
+func (StatusChanged) Name() string     { return "status_changed" }
+func (TariffChanged) Name() string     { return "tariff_changed" }
+func (LoginFailed) Name() string       { return "login_failed" }
@@ -0,0 +1,84 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+type calendarUseCase interface {
+	CalendarByToken(ctx context.Context, token string) ([]byte, error)
+}
+
+// Server serves the calendar feeds the calendar apps subscribe to. The feed is found by the secret token in the
+// path, so the server can be exposed publicly, unlike the ops server.
+type Server struct {
+	logger *slog.Logger
+	server *http.Server
+
+	calendarUseCase calendarUseCase
+}
+
+func NewServer(logger *slog.Logger, addr string, calendarUseCase calendarUseCase) *Server {
+	srv := &Server{
+		logger:          logger.With("component", "calendar"),
+		calendarUseCase: calendarUseCase,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendar/{token}", srv.handlerCalendar)
+
+	srv.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return srv
+}
+
+// Start serves the calendar feeds until the context is canceled.
+func (that *Server) Start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := that.server.Shutdown(shutdownCtx); err != nil {
+			that.logger.Error("Error shutting down calendar server", "error", err)
+		}
+	}()
+
+	that.logger.Info("Starting calendar server", "addr", that.server.Addr)
+	if err := that.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		that.logger.Error("Error serving calendar server", "error", err)
+	}
+}
+
+func (that *Server) handlerCalendar(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("token"), ".ics")
+
+	calendar, err := that.calendarUseCase.CalendarByToken(r.Context(), token)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		that.logger.Error("Error building calendar", "method", "handlerCalendar", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = w.Write(calendar)
+}
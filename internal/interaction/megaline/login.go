@@ -0,0 +1,132 @@
+package megaline
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// LoginStatus is the outcome of a login to the personal cabinet recognized from the response page.
+type LoginStatus string
+
+const (
+	LoginStatusSuccess        LoginStatus = "success"
+	LoginStatusBadCredentials LoginStatus = "bad_credentials"
+	LoginStatusCaptcha        LoginStatus = "captcha"
+	LoginStatusBlocked        LoginStatus = "blocked"
+	LoginStatusMaintenance    LoginStatus = "maintenance"
+	LoginStatusUnknown        LoginStatus = "unknown"
+)
+
+var (
+	maintenanceMarkers = []string{"технические работы", "техническое обслуживание", "временно недоступен", "maintenance"}
+	blockedMarkers     = []string{"слишком много попыток", "превышено количество попыток", "too many attempts"}
+	badLoginMarkers    = []string{"неверный логин", "неверный пароль", "неправильный логин", "неправильный пароль", "неверно указан"}
+)
+
+// LoginResult is the response of the personal cabinet to the login.
+type LoginResult struct {
+	Status  LoginStatus
+	Body    []byte
+	Session string
+	// Captcha is set when the status is LoginStatusCaptcha.
+	Captcha *Captcha
+}
+
+// Captcha is the challenge shown on the login page, the answer is submitted with SubmitCaptcha in the same session.
+type Captcha struct {
+	ImageURL string
+	Field    string
+	Image    []byte
+}
+
+// ClassifyLogin recognizes the outcome of the login from the response page. The captcha is checked before the
+// error messages, as the page with a captcha usually repeats the login form and the error as well.
+func ClassifyLogin(body []byte) LoginStatus {
+	page := strings.ToLower(string(body))
+
+	switch {
+	case isLoggedIn(body):
+		return LoginStatusSuccess
+	case findCaptcha(body) != nil:
+		return LoginStatusCaptcha
+	case containsAny(page, blockedMarkers):
+		return LoginStatusBlocked
+	case containsAny(page, maintenanceMarkers):
+		return LoginStatusMaintenance
+	case containsAny(page, badLoginMarkers), strings.Contains(page, `name="pass"`):
+		return LoginStatusBadCredentials
+	default:
+		return LoginStatusUnknown
+	}
+}
+
+// isLoggedIn reports whether the page is the page of the personal cabinet shown to the logged-in users.
+func isLoggedIn(body []byte) bool {
+	return strings.Contains(strings.ToLower(string(body)), strings.ToLower(accountMarker))
+}
+
+// findCaptcha returns the captcha of the login form, the image URL is resolved against the login page.
+func findCaptcha(body []byte) *Captcha {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	image := doc.Find("img").FilterFunction(func(i int, s *goquery.Selection) bool {
+		src, _ := s.Attr("src")
+		id, _ := s.Attr("id")
+		return strings.Contains(strings.ToLower(src+" "+id), "captcha")
+	}).First()
+
+	src, ok := image.Attr("src")
+	if !ok {
+		return nil
+	}
+
+	field := ""
+	doc.Find("input").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		name, _ := s.Attr("name")
+		if strings.Contains(strings.ToLower(name), "captcha") || strings.Contains(strings.ToLower(name), "code") {
+			field = name
+			return false
+		}
+
+		return true
+	})
+
+	if field == "" {
+		return nil
+	}
+
+	base, _ := url.Parse(loginURL)
+	ref, err := url.Parse(src)
+	if err != nil {
+		return nil
+	}
+
+	return &Captcha{ImageURL: base.ResolveReference(ref).String(), Field: field}
+}
+
+// isCabinetURL reports whether the URL points to the personal cabinet over HTTPS, so that the session is not sent
+// anywhere else.
+func isCabinetURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "https" && parsed.Hostname() == cabinetHost && parsed.User == nil
+}
+
+func containsAny(page string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(page, marker) {
+			return true
+		}
+	}
+
+	return false
+}
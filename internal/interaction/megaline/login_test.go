@@ -0,0 +1,125 @@
+package megaline
+
+import "testing"
+
+const (
+	loginFormPage = `<form method="post"><input name="login"><input type="password" name="pass"></form>`
+	captchaPage   = `<div class="error">Неверный пароль</div>
+<form method="post">
+  <input name="login"><input type="password" name="pass">
+  <img id="captcha_img" src="/captcha.php?sid=42"><input name="captcha_code">
+</form>`
+)
+
+func TestClassifyLogin(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want LoginStatus
+	}{
+		{name: "personal cabinet", body: `<div>Лицевой счет № 996555000001</div>`, want: LoginStatusSuccess},
+		{name: "captcha with the error repeated", body: captchaPage, want: LoginStatusCaptcha},
+		{name: "too many attempts", body: `<p>Слишком много попыток входа</p>` + loginFormPage, want: LoginStatusBlocked},
+		{name: "maintenance", body: `<h1>Ведутся технические работы</h1>`, want: LoginStatusMaintenance},
+		{name: "wrong password", body: `<div class="error">Неверный логин или пароль</div>`, want: LoginStatusBadCredentials},
+		{name: "login form shown again", body: loginFormPage, want: LoginStatusBadCredentials},
+		{name: "captcha image without the answer field", body: `<img src="/captcha.php">`, want: LoginStatusUnknown},
+		{name: "unknown page", body: `<html><body>502 Bad Gateway</body></html>`, want: LoginStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyLogin([]byte(tt.body)); got != tt.want {
+				t.Errorf("ClassifyLogin() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCaptcha(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *Captcha
+	}{
+		{
+			name: "relative image",
+			body: captchaPage,
+			want: &Captcha{ImageURL: "https://bill.mega.kg/captcha.php?sid=42", Field: "captcha_code"},
+		},
+		{
+			name: "image recognized by id",
+			body: `<img id="captcha" src="img.php?r=1"><input name="code">`,
+			want: &Captcha{ImageURL: "https://bill.mega.kg/img.php?r=1", Field: "code"},
+		},
+		{
+			name: "absolute image on another host",
+			body: `<img src="https://evil.example.com/captcha.png"><input name="captcha">`,
+			want: &Captcha{ImageURL: "https://evil.example.com/captcha.png", Field: "captcha"},
+		},
+		{name: "no captcha", body: loginFormPage},
+		{name: "no answer field", body: `<img src="/captcha.php">` + loginFormPage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findCaptcha([]byte(tt.body))
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("findCaptcha() = %+v, want nil", got)
+				}
+
+				return
+			}
+
+			if got == nil || got.ImageURL != tt.want.ImageURL || got.Field != tt.want.Field {
+				t.Errorf("findCaptcha() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCabinetURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://bill.mega.kg/captcha.php?sid=42", want: true},
+		{url: "https://bill.mega.kg:443/captcha.php", want: true},
+		{url: "http://bill.mega.kg/captcha.php", want: false},
+		{url: "https://evil.example.com/captcha.png", want: false},
+		{url: "https://bill.mega.kg.evil.example.com/captcha.png", want: false},
+		{url: "https://bill.mega.kg@evil.example.com/captcha.png", want: false},
+		{url: "https://user@bill.mega.kg/captcha.png", want: false},
+		{url: "//evil.example.com/captcha.png", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := isCabinetURL(tt.url); got != tt.want {
+				t.Errorf("isCabinetURL(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsLoggedIn(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{name: "billing page", body: `<div class="account_info">Лицевой счет № 996555000001</div>`, want: true},
+		{name: "marker in other case", body: `<div>ЛИЦЕВОЙ СЧЕТ № 996555000001</div>`, want: true},
+		{name: "login page of the expired session", body: loginFormPage, want: false},
+		{name: "empty page", body: ``, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLoggedIn([]byte(tt.body)); got != tt.want {
+				t.Errorf("isLoggedIn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,16 +2,36 @@ package megaline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
 )
 
+// accountMarker is shown on the pages of the personal cabinet only to the logged-in users.
+const accountMarker = "Лицевой счет №"
+
+// ErrSessionExpired is returned when the personal cabinet shows the login page instead of the requested one, the
+// session is no longer logged in and the login must be repeated.
+var ErrSessionExpired = errors.New("session expired")
+
+// cabinetHost is the host of the personal cabinet, the session cookie is sent only to it.
+const cabinetHost = "bill.mega.kg"
+
 const (
 	loginURL   = "https://bill.mega.kg/?page=login"
 	indexURL   = "https://bill.mega.kg/index.php"
 	billingURL = "https://bill.mega.kg/page.php?page=main"
+	// paymentsURL is not verified against the live personal cabinet yet. ParsePayments finds the table by the
+	// headers, so a page without the payments yields no records and shows up only in the empty /payments.
+	paymentsURL = "https://bill.mega.kg/page.php?page=payments"
+	profileURL  = "https://bill.mega.kg/page.php?page=profile"
 )
 
 type Connector struct {
@@ -24,19 +44,50 @@ func NewConnector(client http.Client) *Connector {
 	}
 }
 
-func (that *Connector) Login(ctx context.Context, username, password string) ([]byte, string, error) {
+// Login logs in to the personal cabinet in a new session and classifies the response.
+func (that *Connector) Login(ctx context.Context, username, password string) (*LoginResult, error) {
 	_, sessionID, err := that.makeRequest(ctx, http.MethodGet, loginURL, "", "")
 	if err != nil {
-		return nil, "", fmt.Errorf("get session id: %w", err)
+		return nil, fmt.Errorf("get session id: %w", err)
 	}
 
-	payload := fmt.Sprintf("login=%s&pass=%s&act=login", username, password)
-	body, _, err := that.makeRequest(ctx, http.MethodPost, loginURL, sessionID, payload)
+	return that.submitLogin(ctx, sessionID, loginForm(username, password))
+}
+
+// SubmitCaptcha repeats the login with the answer to the captcha in the session the captcha was shown in.
+func (that *Connector) SubmitCaptcha(ctx context.Context, session, username, password string, captcha *Captcha, answer string) (*LoginResult, error) {
+	form := loginForm(username, password)
+	form.Set(captcha.Field, answer)
+
+	return that.submitLogin(ctx, session, form)
+}
+
+func (that *Connector) submitLogin(ctx context.Context, session string, form url.Values) (*LoginResult, error) {
+	body, _, err := that.makeRequest(ctx, http.MethodPost, loginURL, session, form.Encode())
 	if err != nil {
-		return nil, "", fmt.Errorf("login: %w", err)
+		return nil, fmt.Errorf("login: %w", err)
 	}
 
-	return body, sessionID, nil
+	result := &LoginResult{Status: ClassifyLogin(body), Body: body, Session: session}
+	if result.Status != LoginStatusCaptcha {
+		return result, nil
+	}
+
+	result.Captcha = findCaptcha(body)
+	if !isCabinetURL(result.Captcha.ImageURL) {
+		return nil, fmt.Errorf("captcha image outside of the personal cabinet: %s", result.Captcha.ImageURL)
+	}
+
+	result.Captcha.Image, _, err = that.makeRequest(ctx, http.MethodGet, result.Captcha.ImageURL, session, "")
+	if err != nil {
+		return nil, fmt.Errorf("get captcha image: %w", err)
+	}
+
+	return result, nil
+}
+
+func loginForm(username, password string) url.Values {
+	return url.Values{"login": {username}, "pass": {password}, "act": {"login"}}
 }
 
 func (that *Connector) GetAccountsDetail(ctx context.Context, session, account string) ([]byte, error) {
@@ -49,6 +100,38 @@ func (that *Connector) GetAccountsDetail(ctx context.Context, session, account s
 		return nil, fmt.Errorf("get account detail: %w", err)
 	}
 
+	if !isLoggedIn(body) {
+		return nil, ErrSessionExpired
+	}
+
+	return body, nil
+}
+
+// GetPayments returns the page with the payment history of the account.
+func (that *Connector) GetPayments(ctx context.Context, session, account string) ([]byte, error) {
+	if _, _, err := that.makeRequest(ctx, http.MethodPost, indexURL, session, fmt.Sprintf("ls_change=%s", account)); err != nil {
+		return nil, fmt.Errorf("change account: %w", err)
+	}
+
+	body, _, err := that.makeRequest(ctx, http.MethodGet, paymentsURL, session, "")
+	if err != nil {
+		return nil, fmt.Errorf("get payments: %w", err)
+	}
+
+	return body, nil
+}
+
+// GetProfile returns the page with the subscriber profile of the account.
+func (that *Connector) GetProfile(ctx context.Context, session, account string) ([]byte, error) {
+	if _, _, err := that.makeRequest(ctx, http.MethodPost, indexURL, session, fmt.Sprintf("ls_change=%s", account)); err != nil {
+		return nil, fmt.Errorf("change account: %w", err)
+	}
+
+	body, _, err := that.makeRequest(ctx, http.MethodGet, profileURL, session, "")
+	if err != nil {
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+
 	return body, nil
 }
 
@@ -66,11 +149,18 @@ func (that *Connector) makeRequest(ctx context.Context, method, pageURL, session
 		req.Header.Set("Cookie", fmt.Sprintf("PHPSESSID=%s", session))
 	}
 
+	page := pageLabel(req.URL)
+	startedAt := time.Now()
+
 	resp, err := that.client.Do(req)
+	metrics.MegaLineRequestDuration.WithLabelValues(page, method).Observe(time.Since(startedAt).Seconds())
 	if err != nil {
+		metrics.MegaLineRequests.WithLabelValues(page, method, "error").Inc()
 		return nil, "", fmt.Errorf("make request: %w", err)
 	}
 
+	metrics.MegaLineRequests.WithLabelValues(page, method, strconv.Itoa(resp.StatusCode)).Inc()
+
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
@@ -86,3 +176,12 @@ func (that *Connector) makeRequest(ctx context.Context, method, pageURL, session
 
 	return body, cookieValue, nil
 }
+
+// pageLabel returns the page of the personal cabinet for the metrics, e.g. "login" or "main".
+func pageLabel(pageURL *url.URL) string {
+	if page := pageURL.Query().Get("page"); page != "" {
+		return page
+	}
+
+	return strings.TrimPrefix(pageURL.Path, "/")
+}
@@ -0,0 +1,160 @@
+package megaline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+var (
+	dateRe = regexp.MustCompile(`\b(\d{2})\.(\d{2})\.(\d{4})\b`)
+
+	// accountStatuses are the known descriptions of the status of the service in lower case. The whole description
+	// is matched, the negated ones like "не подключен" contain the opposite status.
+	accountStatuses = map[string]model.AccountStatus{
+		"активен":                model.AccountStatusActive,
+		"активна":                model.AccountStatusActive,
+		"подключен":              model.AccountStatusActive,
+		"подключена":             model.AccountStatusActive,
+		"работает":               model.AccountStatusActive,
+		"услуга работает":        model.AccountStatusActive,
+		"разблокирован":          model.AccountStatusActive,
+		"разблокирована":         model.AccountStatusActive,
+		"не заблокирован":        model.AccountStatusActive,
+		"не блокирован":          model.AccountStatusActive,
+		"не приостановлен":       model.AccountStatusActive,
+		"не отключен":            model.AccountStatusActive,
+		"заблокирован":           model.AccountStatusBlocked,
+		"заблокирована":          model.AccountStatusBlocked,
+		"блокировка":             model.AccountStatusBlocked,
+		"блокировка за неуплату": model.AccountStatusBlocked,
+		"приостановлен":          model.AccountStatusBlocked,
+		"приостановлена":         model.AccountStatusBlocked,
+		"отключен":               model.AccountStatusBlocked,
+		"отключена":              model.AccountStatusBlocked,
+		"не подключен":           model.AccountStatusBlocked,
+		"неактивен":              model.AccountStatusBlocked,
+		"не активен":             model.AccountStatusBlocked,
+	}
+)
+
+// ParseAccountNumbers returns the numbers of the accounts listed in the account selector of a cabinet page.
+func ParseAccountNumbers(body []byte) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse document: %w", err)
+	}
+
+	var numbers []string
+	doc.Find(".account_selector").Find("option").Each(func(i int, s *goquery.Selection) {
+		numbers = append(numbers, strings.TrimSpace(s.Text()))
+	})
+
+	return numbers, nil
+}
+
+// ParseAccountDetail fills the account with the values from the billing page, the dates are read in the location
+// of the personal cabinet. The fields that cannot be parsed are left untouched and reported in the returned error.
+func ParseAccountDetail(body []byte, account *model.Account, loc *time.Location) error {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("parse document: %w", err)
+	}
+
+	var errs []error
+	fail := func(field string, err error) {
+		metrics.ParseFailures.WithLabelValues(field).Inc()
+		errs = append(errs, fmt.Errorf("parse %s: %w", field, err))
+	}
+
+	var info model.AccountInfo
+	doc.Find(".account_info").Find(".span100").Each(func(i int, s *goquery.Selection) {
+		desc := strings.TrimSpace(s.Find(".desc").Text())
+		if key := strings.TrimSpace(strings.TrimSuffix(desc, ":")); key != "" {
+			info.Set(key, strings.Join(strings.Fields(s.Find(".value").Text()), " "))
+		}
+
+		switch desc {
+		case "Баланс":
+			s.Find(".value").Each(func(i int, s *goquery.Selection) {
+				balance, err := model.ParseMoney(s.Text())
+				if err != nil {
+					fail("balance", err)
+					return
+				}
+
+				account.Balance = balance
+			})
+		case "Расчетный период:":
+			s.Find(".value").Each(func(i int, s *goquery.Selection) {
+				period := strings.TrimSpace(s.Text())
+				matches := dateRe.FindAllStringSubmatch(period, -1)
+
+				if len(matches) != 2 {
+					fail("period", fmt.Errorf("unexpected period %q", period))
+					return
+				}
+
+				billingFrom, err := time.ParseInLocation("02.01.2006", matches[0][0], loc)
+				if err != nil {
+					fail("period", err)
+					return
+				}
+
+				billingTo, err := time.ParseInLocation("02.01.2006", matches[1][0], loc)
+				if err != nil {
+					fail("period", err)
+					return
+				}
+
+				account.Billing = model.BillingPeriod{From: billingFrom, To: billingTo}
+			})
+		case "Тарифный план:", "Тариф:":
+			account.TariffName = strings.Join(strings.Fields(s.Find(".value").Text()), " ")
+		case "Статус", "Статус:":
+			status := strings.TrimSpace(s.Find(".value").Text())
+			if parsed := accountStatus(status); parsed != model.AccountStatusUnknown {
+				account.Status = parsed
+			} else {
+				fail("status", fmt.Errorf("unexpected status %q", status))
+			}
+		case "Оплата за период:":
+			s.Find(".value").Each(func(i int, s *goquery.Selection) {
+				payment, err := model.ParseMoney(s.Text())
+				if err != nil {
+					fail("tariff_amount", err)
+					return
+				}
+
+				account.TariffAmount = payment
+			})
+		}
+	})
+
+	if len(info) == 0 {
+		fail("info", errors.New("no account information found"))
+	} else {
+		account.Info = info
+	}
+
+	return errors.Join(errs...)
+}
+
+// accountStatus recognizes the status of the service from its description in the personal cabinet, the case, the
+// spacing and the trailing period are ignored.
+func accountStatus(status string) model.AccountStatus {
+	status = strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(status), " ")), ".")
+	if known, ok := accountStatuses[status]; ok {
+		return known
+	}
+
+	return model.AccountStatusUnknown
+}
@@ -0,0 +1,70 @@
+package megaline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestAccountStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   model.AccountStatus
+	}{
+		{status: "Активен", want: model.AccountStatusActive},
+		{status: "Подключен", want: model.AccountStatusActive},
+		{status: "Услуга работает", want: model.AccountStatusActive},
+		{status: "Разблокирован", want: model.AccountStatusActive},
+		{status: "Не заблокирован", want: model.AccountStatusActive},
+		{status: "Не отключен", want: model.AccountStatusActive},
+		{status: "Заблокирован", want: model.AccountStatusBlocked},
+		{status: "Блокировка за неуплату", want: model.AccountStatusBlocked},
+		{status: "Приостановлен", want: model.AccountStatusBlocked},
+		{status: "Отключен", want: model.AccountStatusBlocked},
+		{status: "Неактивен", want: model.AccountStatusBlocked},
+		{status: "Не подключен", want: model.AccountStatusBlocked},
+		{status: "  Заблокирован. ", want: model.AccountStatusBlocked},
+		{status: "", want: model.AccountStatusUnknown},
+		{status: "В обработке", want: model.AccountStatusUnknown},
+		{status: "Подключен к тарифу", want: model.AccountStatusUnknown},
+		{status: "Не заблокирован, но отключен", want: model.AccountStatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			if got := accountStatus(tt.status); got != tt.want {
+				t.Errorf("accountStatus(%q) = %q, want %q", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccountDetailStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		want    model.AccountStatus
+		wantErr bool
+	}{
+		{name: "known status replaces the stored one", status: "Активен", want: model.AccountStatusActive},
+		{name: "unknown status keeps the stored one", status: "В обработке", want: model.AccountStatusBlocked, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `<div class="account_info"><div class="span100"><span class="desc">Статус</span><span class="value">` + tt.status + `</span></div></div>`
+			account := model.Account{Status: model.AccountStatusBlocked}
+
+			err := ParseAccountDetail([]byte(body), &account, time.UTC)
+			if (err != nil) != tt.wantErr || (err != nil && !strings.Contains(err.Error(), "status")) {
+				t.Errorf("ParseAccountDetail() error = %v, want the status error %v", err, tt.wantErr)
+			}
+
+			if account.Status != tt.want {
+				t.Errorf("status = %q, want %q", account.Status, tt.want)
+			}
+		})
+	}
+}
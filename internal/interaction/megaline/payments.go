@@ -0,0 +1,100 @@
+package megaline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// PaymentRecord is a row of the payment history in the personal cabinet.
+type PaymentRecord struct {
+	PaidAt time.Time
+	Amount model.Money
+	Source string
+}
+
+var paymentLayouts = []string{"02.01.2006 15:04:05", "02.01.2006 15:04", "02.01.2006"}
+
+// ParsePayments returns the payments listed on the payments page, the dates are read in the location of the
+// personal cabinet. The columns are found by their headers, the rows that cannot be parsed are skipped and
+// reported in the returned error.
+func ParsePayments(body []byte, loc *time.Location) ([]PaymentRecord, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse document: %w", err)
+	}
+
+	var (
+		records []PaymentRecord
+		errs    []error
+	)
+
+	doc.Find("table").EachWithBreak(func(i int, table *goquery.Selection) bool {
+		dateColumn, amountColumn, sourceColumn := -1, -1, -1
+		table.Find("tr").First().Find("th, td").Each(func(i int, s *goquery.Selection) {
+			header := strings.ToLower(strings.TrimSpace(s.Text()))
+			switch {
+			case strings.Contains(header, "дата"):
+				dateColumn = i
+			case strings.Contains(header, "сумма"):
+				amountColumn = i
+			case containsAny(header, []string{"способ", "источник", "платеж", "тип"}):
+				sourceColumn = i
+			}
+		})
+
+		if dateColumn < 0 || amountColumn < 0 {
+			return true
+		}
+
+		table.Find("tr").Slice(1, goquery.ToEnd).Each(func(i int, row *goquery.Selection) {
+			cells := row.Find("td").Map(func(i int, s *goquery.Selection) string {
+				return strings.Join(strings.Fields(s.Text()), " ")
+			})
+
+			if len(cells) <= max(dateColumn, amountColumn, sourceColumn) {
+				return
+			}
+
+			record, err := paymentRecord(cells, dateColumn, amountColumn, sourceColumn, loc)
+			if err != nil {
+				metrics.ParseFailures.WithLabelValues("payment").Inc()
+				errs = append(errs, fmt.Errorf("parse payment %d: %w", i+1, err))
+				return
+			}
+
+			records = append(records, record)
+		})
+
+		return false
+	})
+
+	return records, errors.Join(errs...)
+}
+
+func paymentRecord(cells []string, dateColumn, amountColumn, sourceColumn int, loc *time.Location) (PaymentRecord, error) {
+	amount, err := model.ParseMoney(cells[amountColumn])
+	if err != nil {
+		return PaymentRecord{}, err
+	}
+
+	record := PaymentRecord{Amount: amount}
+	if sourceColumn >= 0 {
+		record.Source = cells[sourceColumn]
+	}
+
+	for _, layout := range paymentLayouts {
+		if record.PaidAt, err = time.ParseInLocation(layout, cells[dateColumn], loc); err == nil {
+			return record, nil
+		}
+	}
+
+	return PaymentRecord{}, fmt.Errorf("unexpected date %q", cells[dateColumn])
+}
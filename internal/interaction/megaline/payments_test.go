@@ -0,0 +1,79 @@
+package megaline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestParsePayments(t *testing.T) {
+	bishkek, err := time.LoadLocation("Asia/Bishkek")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		fixture string
+		want    []PaymentRecord
+		// wantErrs are the rows reported in the error, e.g. "parse payment 2".
+		wantErrs []string
+	}{
+		{
+			name:    "date, amount and source columns after a layout table",
+			fixture: "payments.html",
+			want: []PaymentRecord{
+				{PaidAt: time.Date(2026, 10, 5, 14, 30, 15, 0, bishkek), Amount: model.Som(1000), Source: "Терминал"},
+				{PaidAt: time.Date(2026, 10, 12, 9, 5, 0, 0, bishkek), Amount: model.Som(500), Source: "Элсом"},
+				{PaidAt: time.Date(2026, 10, 20, 0, 0, 0, 0, bishkek), Amount: model.Tyiyn(25050), Source: "Банковская карта"},
+			},
+		},
+		{
+			name:    "reordered columns in td headers with bad and short rows",
+			fixture: "payments_reordered.html",
+			want: []PaymentRecord{
+				{PaidAt: time.Date(2026, 11, 1, 23, 59, 59, 0, bishkek), Amount: model.Tyiyn(-1250), Source: "Терминал"},
+				{PaidAt: time.Date(2026, 11, 4, 0, 0, 0, 0, bishkek), Amount: model.Som(75), Source: "Банк"},
+			},
+			wantErrs: []string{`parse payment 2: invalid amount "ошибка"`, `parse payment 3: unexpected date "2026-11-03"`},
+		},
+		{
+			name:    "no payments table",
+			fixture: "payments_empty.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+
+			got, err := ParsePayments(body, bishkek)
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Fatalf("ParsePayments() error = %v", err)
+			}
+
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("ParsePayments() error = %v, want it to report %s", err, want)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParsePayments() = %+v, want %+v", got, tt.want)
+			}
+
+			for i := range got {
+				if !got[i].PaidAt.Equal(tt.want[i].PaidAt) || got[i].Amount != tt.want[i].Amount || got[i].Source != tt.want[i].Source {
+					t.Errorf("payment %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
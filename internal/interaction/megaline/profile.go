@@ -0,0 +1,72 @@
+package megaline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// profileLabels are the labels of the profile fields, normalized by profileLabel. The labels are matched exactly,
+// e.g. "Телефон абонента" is the phone and not the owner.
+var profileLabels = map[string]func(profile *model.Profile) *string{
+	"фио":                func(p *model.Profile) *string { return &p.OwnerName },
+	"ф.и.о.":             func(p *model.Profile) *string { return &p.OwnerName },
+	"абонент":            func(p *model.Profile) *string { return &p.OwnerName },
+	"владелец":           func(p *model.Profile) *string { return &p.OwnerName },
+	"адрес":              func(p *model.Profile) *string { return &p.Address },
+	"адрес подключения":  func(p *model.Profile) *string { return &p.Address },
+	"тариф":              func(p *model.Profile) *string { return &p.TariffPlan },
+	"тарифный план":      func(p *model.Profile) *string { return &p.TariffPlan },
+	"договор":            func(p *model.Profile) *string { return &p.ContractNumber },
+	"номер договора":     func(p *model.Profile) *string { return &p.ContractNumber },
+	"№ договора":         func(p *model.Profile) *string { return &p.ContractNumber },
+	"телефон":            func(p *model.Profile) *string { return &p.Phone },
+	"телефон абонента":   func(p *model.Profile) *string { return &p.Phone },
+	"контактный телефон": func(p *model.Profile) *string { return &p.Phone },
+}
+
+// ParseProfile returns the subscriber profile from the profile page. The fields are recognized by their labels,
+// either in the desc/value rows or in the table rows of the page.
+func ParseProfile(body []byte) (*model.Profile, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse document: %w", err)
+	}
+
+	profile := &model.Profile{}
+	set := func(label, value string) {
+		value = strings.Join(strings.Fields(value), " ")
+		if field, ok := profileLabels[profileLabel(label)]; ok && value != "" {
+			*field(profile) = value
+		}
+	}
+
+	doc.Find(".desc").Each(func(i int, s *goquery.Selection) {
+		set(s.Text(), s.Parent().Find(".value").Text())
+	})
+
+	doc.Find("tr").Each(func(i int, s *goquery.Selection) {
+		if cells := s.Find("th, td"); cells.Length() == 2 {
+			set(cells.First().Text(), cells.Last().Text())
+		}
+	})
+
+	if *profile == (model.Profile{}) {
+		metrics.ParseFailures.WithLabelValues("profile").Inc()
+		return nil, errors.New("no profile fields found")
+	}
+
+	return profile, nil
+}
+
+// profileLabel returns the label in lower case without the extra spaces and the trailing colon.
+func profileLabel(label string) string {
+	label = strings.ToLower(strings.Join(strings.Fields(label), " "))
+	return strings.TrimSpace(strings.TrimSuffix(label, ":"))
+}
@@ -0,0 +1,71 @@
+package megaline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestParseProfile(t *testing.T) {
+	profilePage, err := os.ReadFile(filepath.Join("testdata", "profile.html"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    *model.Profile
+		wantErr bool
+	}{
+		{
+			name: "desc rows and table rows",
+			body: string(profilePage),
+			want: &model.Profile{
+				OwnerName:      "Иванов Иван Иванович",
+				Address:        "г. Бишкек, ул. Киевская, 1",
+				TariffPlan:     "Оптимальный 100",
+				ContractNumber: "KG-000123",
+				Phone:          "+996 555 123 456",
+			},
+		},
+		{
+			name: "labels without the colon in other case",
+			body: `<table><tr><td>ФИО</td><td>Петров П.</td></tr><tr><td>ТЕЛЕФОН :</td><td>0555</td></tr></table>`,
+			want: &model.Profile{OwnerName: "Петров П.", Phone: "0555"},
+		},
+		{
+			name: "empty values are skipped",
+			body: `<table><tr><td>Абонент</td><td> </td></tr><tr><td>Тариф</td><td>Базовый</td></tr></table>`,
+			want: &model.Profile{TariffPlan: "Базовый"},
+		},
+		{
+			name:    "only unknown labels",
+			body:    `<table><tr><td>Абонентская плата</td><td>950 сом</td></tr><tr><td>Телефон поддержки</td><td>0312</td></tr></table>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProfile([]byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseProfile() = %+v, want an error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseProfile() error = %v", err)
+			}
+
+			if *got != *tt.want {
+				t.Errorf("ParseProfile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
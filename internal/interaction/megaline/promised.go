@@ -0,0 +1,75 @@
+package megaline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const promisedPaymentURL = "https://bill.mega.kg/page.php?page=promised"
+
+// promisedMessageSelector finds the message with the result of the request, the rest of the page, e.g. the menu or
+// the terms of the promised payment, is not classified.
+const promisedMessageSelector = ".result, .message, .msg, .alert, .error, .success, .notice, #result, #message"
+
+// PromisedPaymentStatus is the outcome of the request for the promised payment, which restores the service for
+// a few days before the account is topped up.
+type PromisedPaymentStatus string
+
+const (
+	PromisedPaymentGranted     PromisedPaymentStatus = "granted"
+	PromisedPaymentAlreadyUsed PromisedPaymentStatus = "already_used"
+	PromisedPaymentIneligible  PromisedPaymentStatus = "ineligible"
+	PromisedPaymentUnknown     PromisedPaymentStatus = "unknown"
+)
+
+var (
+	promisedUsedMarkers       = []string{"уже воспользовались", "уже подключен", "уже активирован", "уже предоставлен"}
+	promisedIneligibleMarkers = []string{"недоступ", "невозможно", "не может быть", "не предоставляется", "не доступ"}
+	promisedNegatedMarkers    = []string{"не подключен", "не предоставлен", "не активирован", "не удалось", "отказ"}
+	promisedGrantedMarkers    = []string{"успешно", "подключен", "предоставлен", "активирован"}
+)
+
+// RequestPromisedPayment requests the promised payment for the account and classifies the response.
+func (that *Connector) RequestPromisedPayment(ctx context.Context, session, account string) (PromisedPaymentStatus, error) {
+	if _, _, err := that.makeRequest(ctx, http.MethodPost, indexURL, session, fmt.Sprintf("ls_change=%s", account)); err != nil {
+		return "", fmt.Errorf("change account: %w", err)
+	}
+
+	body, _, err := that.makeRequest(ctx, http.MethodPost, promisedPaymentURL, session, url.Values{"act": {"promised"}}.Encode())
+	if err != nil {
+		return "", fmt.Errorf("request promised payment: %w", err)
+	}
+
+	return ClassifyPromisedPayment(body), nil
+}
+
+// ClassifyPromisedPayment recognizes the outcome of the promised payment request from the result message of the
+// response page. The refusals and the negations are checked first, as they repeat the words of the success
+// message, e.g. "уже подключен" or "не подключен". The page without a recognized message is unknown.
+func ClassifyPromisedPayment(body []byte) PromisedPaymentStatus {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return PromisedPaymentUnknown
+	}
+
+	message := strings.ToLower(strings.Join(strings.Fields(doc.Find(promisedMessageSelector).Text()), " "))
+
+	switch {
+	case message == "":
+		return PromisedPaymentUnknown
+	case containsAny(message, promisedUsedMarkers):
+		return PromisedPaymentAlreadyUsed
+	case containsAny(message, promisedIneligibleMarkers), containsAny(message, promisedNegatedMarkers):
+		return PromisedPaymentIneligible
+	case containsAny(message, promisedGrantedMarkers):
+		return PromisedPaymentGranted
+	default:
+		return PromisedPaymentUnknown
+	}
+}
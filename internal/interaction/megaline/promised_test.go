@@ -0,0 +1,56 @@
+package megaline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyPromisedPayment(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    PromisedPaymentStatus
+	}{
+		{fixture: "promised_granted.html", want: PromisedPaymentGranted},
+		{fixture: "promised_used.html", want: PromisedPaymentAlreadyUsed},
+		{fixture: "promised_not_granted.html", want: PromisedPaymentIneligible},
+		{fixture: "promised_form.html", want: PromisedPaymentUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+
+			if got := ClassifyPromisedPayment(body); got != tt.want {
+				t.Errorf("ClassifyPromisedPayment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyPromisedPaymentMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want PromisedPaymentStatus
+	}{
+		{name: "not provided", body: `<div class="error">Обещанный платеж не предоставлен</div>`, want: PromisedPaymentIneligible},
+		{name: "unavailable", body: `<div class="notice">Услуга недоступна для вашего тарифа</div>`, want: PromisedPaymentIneligible},
+		{name: "already activated", body: `<div class="message">Обещанный платеж уже активирован</div>`, want: PromisedPaymentAlreadyUsed},
+		{name: "activated", body: `<p class="result">Обещанный платеж активирован</p>`, want: PromisedPaymentGranted},
+		{name: "message without markers", body: `<div class="message">Заявка принята</div>`, want: PromisedPaymentUnknown},
+		{name: "empty message", body: `<div class="message"> </div><p>Обещанный платеж подключен</p>`, want: PromisedPaymentUnknown},
+		{name: "no message", body: `<p>Обещанный платеж успешно подключен</p>`, want: PromisedPaymentUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyPromisedPayment([]byte(tt.body)); got != tt.want {
+				t.Errorf("ClassifyPromisedPayment() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
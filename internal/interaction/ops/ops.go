@@ -0,0 +1,103 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+type database interface {
+	Ping(ctx context.Context) error
+}
+
+type poller interface {
+	LastPoll() time.Time
+}
+
+type Server struct {
+	logger *slog.Logger
+	server *http.Server
+
+	database       database
+	poller         poller
+	pollStaleAfter time.Duration
+}
+
+func NewServer(logger *slog.Logger, addr string, pollStaleAfter time.Duration, database database, poller poller) *Server {
+	srv := &Server{
+		logger:         logger.With("component", "ops"),
+		database:       database,
+		poller:         poller,
+		pollStaleAfter: pollStaleAfter,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", srv.handlerHealth)
+	mux.HandleFunc("GET /readyz", srv.handlerReady)
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	srv.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return srv
+}
+
+// Start serves the ops endpoints until the context is canceled.
+func (that *Server) Start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := that.server.Shutdown(shutdownCtx); err != nil {
+			that.logger.Error("Error shutting down ops server", "error", err)
+		}
+	}()
+
+	that.logger.Info("Starting ops server", "addr", that.server.Addr)
+	if err := that.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		that.logger.Error("Error serving ops server", "error", err)
+	}
+}
+
+func (that *Server) handlerHealth(w http.ResponseWriter, _ *http.Request) {
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+func (that *Server) handlerReady(w http.ResponseWriter, r *http.Request) {
+	log := that.logger.With("method", "handlerReady")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := that.database.Ping(ctx); err != nil {
+		log.Warn("Database is not ready", "error", err)
+		writeStatus(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+
+	if since := time.Since(that.poller.LastPoll()); since > that.pollStaleAfter {
+		log.Warn("Telegram polling is stale", "since", since)
+		writeStatus(w, http.StatusServiceUnavailable, fmt.Sprintf("telegram: last successful poll %s ago", since.Round(time.Second)))
+		return
+	}
+
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+func writeStatus(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(text + "\n"))
+}
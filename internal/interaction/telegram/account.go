@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func (that *Connector) handlerAccount(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerAccount", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	account, responseText := selectAccount(user.Accounts, commandArgs(update.Message.Text), "/account")
+	if account != nil && len(account.Info) == 0 {
+		account, responseText = nil, "Информация об аккаунте ещё не загружена. Обновите баланс командой /balance."
+	}
+
+	if account == nil {
+		_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   responseText,
+		})
+
+		if err != nil {
+			log.Error("Error sending message", "error", err)
+			return
+		}
+
+		return
+	}
+
+	message := fmt.Sprintf("📱 *Аккаунт %s*\n", telegramBot.EscapeMarkdown(account.Number))
+	for _, field := range account.Info {
+		message += fmt.Sprintf("\n*%s*: %s", telegramBot.EscapeMarkdown(field.Key), telegramBot.EscapeMarkdown(field.Value))
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
+
+// selectAccount returns the account of the user with the number from the command arguments. Without the number
+// the only account of the user is returned. When no account is selected, the text explaining why is returned.
+func selectAccount(accounts []model.Account, args []string, command string) (*model.Account, string) {
+	if len(accounts) == 0 {
+		return nil, "У вас пока нет аккаунтов. Сохраните логин и пароль командой /save и запросите баланс командой /balance."
+	}
+
+	if len(args) == 0 && len(accounts) == 1 {
+		return &accounts[0], ""
+	}
+
+	numbers := make([]string, 0, len(accounts))
+	for i := range accounts {
+		if len(args) > 0 && accounts[i].Number == args[0] {
+			return &accounts[i], ""
+		}
+
+		numbers = append(numbers, accounts[i].Number)
+	}
+
+	return nil, fmt.Sprintf("Укажите номер аккаунта: %s <номер>\nВаши аккаунты: %s", command, strings.Join(numbers, ", "))
+}
@@ -0,0 +1,102 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const auditQueryLimit = 20
+
+// adminOnly lets only the admins from the config run the command, the others are ignored as if the command
+// doesn't exist.
+func (that *Connector) adminOnly(next telegramBot.HandlerFunc) telegramBot.HandlerFunc {
+	return func(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+		if _, ok := that.admins[update.Message.From.ID]; !ok {
+			that.logger.Warn("Admin command from non-admin", "user_id", update.Message.From.ID, "text", commandName(update.Message.Text))
+			return
+		}
+
+		next(ctx, bot, update)
+	}
+}
+
+// handlerAudit shows the latest audit events: "/audit" for all the events, "/audit <telegram_id>" for the events
+// of the user and "/audit <action>" for the events of the action, e.g. "/audit login.failed".
+func (that *Connector) handlerAudit(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerAudit", "user_id", update.Message.From.ID)
+
+	action, userID := "", int64(0)
+	if args := commandArgs(update.Message.Text); len(args) > 0 {
+		if id, err := strconv.ParseInt(args[0], 10, 64); err == nil {
+			userID = id
+		} else {
+			action = args[0]
+		}
+	}
+
+	_ = that.auditUseCase.Record(ctx, model.AuditActionAdminAuditQueried, update.Message.From.ID, strings.Join(commandArgs(update.Message.Text), " "))
+
+	message := "Событий не найдено."
+
+	events, err := that.auditUseCase.Query(ctx, action, userID, auditQueryLimit)
+	if err != nil {
+		message = "Произошла ошибка при получении журнала. Попробуйте позже."
+	} else if len(events) > 0 {
+		lines := make([]string, 0, len(events))
+		for _, event := range events {
+			line := fmt.Sprintf("%s %s %s", event.CreatedAt.Format("2006-01-02 15:04:05"), event.Action, event.ActorHash[:12])
+			if event.Details != "" {
+				line += " — " + event.Details
+			}
+
+			lines = append(lines, line)
+		}
+
+		message = strings.Join(lines, "\n")
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   message,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
+
+// handlerUnlock clears the lockout of the failed logins: "/unlock <telegram_id|megaline_login>".
+func (that *Connector) handlerUnlock(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerUnlock", "user_id", update.Message.From.ID)
+
+	message := "Использование: /unlock <telegram_id|логин MegaLine>"
+	if args := commandArgs(update.Message.Text); len(args) == 1 {
+		cleared, err := that.loginGuard.Unlock(ctx, update.Message.From.ID, args[0])
+		switch {
+		case err != nil:
+			message = "Произошла ошибка при снятии блокировки. Попробуйте позже."
+		case cleared == 0:
+			message = "Блокировок не найдено."
+		default:
+			message = "Блокировка снята."
+		}
+	}
+
+	_, err := bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   message,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
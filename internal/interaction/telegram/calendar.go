@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+// handlerCalendar sends the calendar of the due dates as a document. With "link" it issues the URL of the feed
+// to subscribe to, with "revoke" it stops the feed.
+func (that *Connector) handlerCalendar(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerCalendar", "user_id", update.Message.From.ID)
+
+	args := commandArgs(update.Message.Text)
+	if len(args) == 0 {
+		calendar, err := that.calendarUseCase.Calendar(ctx, update.Message.From.ID)
+		if err == nil {
+			_, err = bot.SendDocument(ctx, &telegramBot.SendDocumentParams{
+				ChatID:   update.Message.Chat.ID,
+				Document: &models.InputFileUpload{Filename: "megaline.ics", Data: bytes.NewReader(calendar)},
+				Caption:  "Даты оплаты ваших аккаунтов. Откройте файл, чтобы добавить их в календарь, или подпишитесь на обновления командой /calendar link.",
+			})
+
+			if err != nil {
+				log.Error("Error sending document", "error", err)
+			}
+
+			return
+		}
+
+		log.Error("Error building calendar", "error", err)
+
+		responseText := "Произошла ошибка при создании календаря. Попробуйте позже."
+		if errors.Is(err, storage.ErrNotFound) {
+			responseText = "У меня нет ваших данных."
+		}
+
+		that.replyCalendar(ctx, bot, update.Message.Chat.ID, responseText)
+		return
+	}
+
+	var responseText string
+	switch args[0] {
+	case "link":
+		feedURL, err := that.calendarUseCase.IssueFeedURL(ctx, update.Message.From.ID)
+		switch {
+		case errors.Is(err, usecase.ErrCalendarFeedDisabled):
+			responseText = "Подписка на календарь не настроена на этом боте. Используйте /calendar, чтобы получить файл."
+		case err != nil:
+			log.Error("Error issuing calendar feed", "error", err)
+			responseText = "Произошла ошибка при создании подписки. Попробуйте позже."
+		default:
+			responseText = "Ссылка для подписки в приложении календаря:\n" + feedURL + "\n\nНикому её не показывайте. Новая ссылка отменяет старую, /calendar revoke отключает подписку."
+		}
+	case "revoke":
+		responseText = "Подписка на календарь отключена."
+		if err := that.calendarUseCase.RevokeFeed(ctx, update.Message.From.ID); err != nil {
+			log.Error("Error revoking calendar feed", "error", err)
+			responseText = "Произошла ошибка при отключении подписки. Попробуйте позже."
+		}
+	default:
+		responseText = "Используйте /calendar, /calendar link или /calendar revoke."
+	}
+
+	that.replyCalendar(ctx, bot, update.Message.Chat.ID, responseText)
+}
+
+func (that *Connector) replyCalendar(ctx context.Context, bot *telegramBot.Bot, chatID int64, text string) {
+	disabled := true
+	_, err := bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:             chatID,
+		Text:               text,
+		LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: &disabled},
+	})
+
+	if err != nil {
+		that.logger.Error("Error sending message", "method", "replyCalendar", "error", err)
+	}
+}
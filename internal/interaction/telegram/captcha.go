@@ -0,0 +1,33 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// sendCaptcha shows the captcha MegaLine asked for on login and waits for the answer in the next message.
+func (that *Connector) sendCaptcha(ctx context.Context, bot *telegramBot.Bot, chatID, userID int64, image []byte) {
+	log := that.logger.With("method", "sendCaptcha", "user_id", userID)
+
+	_, err := bot.SendPhoto(ctx, &telegramBot.SendPhotoParams{
+		ChatID:  chatID,
+		Photo:   &models.InputFileUpload{Filename: "captcha.png", Data: bytes.NewReader(image)},
+		Caption: "MegaLine просит ввести код с картинки. Отправьте его следующим сообщением, и я войду в личный кабинет.",
+	})
+
+	if err != nil {
+		log.Error("Error sending photo", "error", err)
+		return
+	}
+
+	that.await(userID, awaitingCaptcha)
+}
+
+func (that *Connector) handleWaitingForCaptcha(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	err := that.useCase.SolveCaptcha(ctx, update.Message.From.ID, strings.TrimSpace(update.Message.Text))
+	that.sendBalance(ctx, bot, update.Message.Chat.ID, update.Message.From.ID, err)
+}
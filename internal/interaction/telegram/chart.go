@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/chart"
+)
+
+const defaultChartPeriod = "3m"
+
+var chartPeriodRe = regexp.MustCompile(`^(\d{1,3})([dwmy])$`)
+
+// handlerChart sends the chart of the balance for /chart [account] [period], the period is e.g. 30d, 2w, 3m, 1y
+// or all.
+func (that *Connector) handlerChart(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerChart", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	var accountArgs []string
+	now := time.Now()
+	since, _ := chartPeriodStart(defaultChartPeriod, now)
+	for _, arg := range commandArgs(update.Message.Text) {
+		if start, ok := chartPeriodStart(arg, now); ok {
+			since = start
+			continue
+		}
+
+		accountArgs = append(accountArgs, arg)
+	}
+
+	var image []byte
+	account, responseText := selectAccount(user.Accounts, accountArgs, "/chart")
+	if account != nil {
+		image, err = that.chartUseCase.BalanceChart(ctx, *account, since)
+		switch {
+		case errors.Is(err, chart.ErrNoData):
+			responseText = "За этот период нет истории баланса. Запросите баланс командой /balance или выберите период побольше, например /chart all."
+		case err != nil:
+			log.Error("Error rendering chart", "error", err)
+			responseText = "Произошла ошибка при построении графика. Попробуйте позже."
+		}
+	}
+
+	if image == nil {
+		_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   responseText,
+		})
+
+		if err != nil {
+			log.Error("Error sending message", "error", err)
+			return
+		}
+
+		return
+	}
+
+	_, err = bot.SendPhoto(ctx, &telegramBot.SendPhotoParams{
+		ChatID:  update.Message.Chat.ID,
+		Photo:   &models.InputFileUpload{Filename: "chart.png", Data: bytes.NewReader(image)},
+		Caption: fmt.Sprintf("Баланс аккаунта %s. Зелёные линии — пополнения, фиолетовые — начало расчетного периода, красная — нулевой баланс.", account.Number),
+	})
+
+	if err != nil {
+		log.Error("Error sending photo", "error", err)
+		return
+	}
+}
+
+// chartPeriodStart returns the start of the period like 30d, 2w, 3m, 1y or all before now, the zero time for all.
+func chartPeriodStart(period string, now time.Time) (time.Time, bool) {
+	if period == "all" {
+		return time.Time{}, true
+	}
+
+	matches := chartPeriodRe.FindStringSubmatch(period)
+	if matches == nil {
+		return time.Time{}, false
+	}
+
+	n, _ := strconv.Atoi(matches[1])
+	switch matches[2] {
+	case "d":
+		return now.AddDate(0, 0, -n), true
+	case "w":
+		return now.AddDate(0, 0, -7*n), true
+	case "m":
+		return now.AddDate(0, -n, 0), true
+	default:
+		return now.AddDate(-n, 0, 0), true
+	}
+}
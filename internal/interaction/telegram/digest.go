@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// handlerDigest turns the digest of the billing period on or off for /digest [account] on|off, without on or off
+// it tells whether the digest is on.
+func (that *Connector) handlerDigest(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerDigest", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	var accountArgs []string
+	toggle := ""
+	for _, arg := range commandArgs(update.Message.Text) {
+		if arg == "on" || arg == "off" {
+			toggle = arg
+			continue
+		}
+
+		accountArgs = append(accountArgs, arg)
+	}
+
+	account, responseText := selectAccount(user.Accounts, accountArgs, "/digest")
+	switch {
+	case account == nil:
+	case toggle == "" && account.DigestEnabled:
+		responseText = fmt.Sprintf("Итоги периода для аккаунта %s включены. Отключить: /digest %s off", account.Number, account.Number)
+	case toggle == "":
+		responseText = fmt.Sprintf("Итоги периода для аккаунта %s выключены. Включить: /digest %s on", account.Number, account.Number)
+	default:
+		if err = that.useCase.SetDigest(ctx, update.Message.From.ID, account.Number, toggle == "on"); err != nil {
+			log.Error("Error setting digest", "error", err)
+			responseText = "Произошла ошибка при сохранении настройки. Попробуйте позже."
+		} else if toggle == "on" {
+			responseText = fmt.Sprintf("Готово. Когда начнётся новый расчетный период, я пришлю итоги прошлого для аккаунта %s: списание, пополнения, баланс и следующую дату оплаты.", account.Number)
+		} else {
+			responseText = fmt.Sprintf("Готово. Итоги периода для аккаунта %s больше не будут приходить.", account.Number)
+		}
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   responseText,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
@@ -0,0 +1,58 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+)
+
+func (that *Connector) handlerExport(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerExport", "user_id", update.Message.From.ID)
+
+	export, err := that.privacyUseCase.Export(ctx, update.Message.From.ID)
+	if err != nil {
+		responseText := "Произошла ошибка при выгрузке данных. Попробуйте позже."
+		if errors.Is(err, storage.ErrNotFound) {
+			responseText = "У меня нет ваших данных."
+		}
+
+		_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   responseText,
+		})
+
+		if err != nil {
+			log.Error("Error sending message", "error", err)
+			return
+		}
+
+		return
+	}
+
+	document, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Error("Error marshaling export", "error", err)
+		return
+	}
+
+	_, err = bot.SendDocument(ctx, &telegramBot.SendDocumentParams{
+		ChatID: update.Message.Chat.ID,
+		Document: &models.InputFileUpload{
+			Filename: fmt.Sprintf("megaline-export-%s.json", export.ExportedAt.Format("2006-01-02")),
+			Data:     bytes.NewReader(document),
+		},
+		Caption: "Все данные, которые я храню о вас. Пароль и сессия скрыты.",
+	})
+
+	if err != nil {
+		log.Error("Error sending document", "error", err)
+		return
+	}
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// forecastHorizons are the months ahead the top-up is shown for when /forecast is called without a date.
+var forecastHorizons = []int{3, 6, 12}
+
+// handlerForecast sends the forecast of the balance for /forecast [account] [date], with the date in the format
+// 02.01.2006 the top-up to last until it is shown.
+func (that *Connector) handlerForecast(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerForecast", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	var accountArgs []string
+	var until []time.Time
+	for _, arg := range commandArgs(update.Message.Text) {
+		if day, err := time.ParseInLocation("02.01.2006", arg, that.location); err == nil {
+			until = append(until, day)
+			continue
+		}
+
+		accountArgs = append(accountArgs, arg)
+	}
+
+	account, responseText := selectAccount(user.Accounts, accountArgs, "/forecast")
+	if account != nil {
+		responseText = that.forecastMessage(*account, until, time.Now())
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   responseText,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
+
+// forecastMessage returns the detailed forecast of the account with the top-ups to last until the days, until the
+// forecastHorizons from now if no day is given.
+func (that *Connector) forecastMessage(account model.Account, until []time.Time, now time.Time) string {
+	forecast, ok := that.forecastUseCase.Forecast(account)
+	if !ok {
+		return fmt.Sprintf("Для аккаунта %s ещё нет тарифа или расчетного периода. Обновите баланс командой /balance.", account.Number)
+	}
+
+	billing := account.Billing.In(that.location)
+	message := fmt.Sprintf("📈 Прогноз для аккаунта %s\n\n", account.Number)
+	message += fmt.Sprintf("💰 Баланс: %s\n💳 Сумма тарифа: %s\n", account.Balance, account.TariffAmount)
+	message += fmt.Sprintf("📅 Текущий период: %s — %s\n\n", billing.From.Format("02.01.2006"), billing.To.Format("02.01.2006"))
+
+	if forecast.PeriodsCovered == 0 {
+		message += fmt.Sprintf("Баланса не хватит на следующий период, он начнётся %s.\n", forecast.RunsOutOn.Format("02.01.2006"))
+	} else {
+		message += fmt.Sprintf("Баланса хватит на периодов: %d, оплачено до %s включительно.\n", forecast.PeriodsCovered, forecast.PaidUntil.Format("02.01.2006"))
+		message += fmt.Sprintf("После этого останется %s, деньги закончатся %s.\n", forecast.Remainder, forecast.RunsOutOn.Format("02.01.2006"))
+	}
+
+	message += fmt.Sprintf("Чтобы оплатить ещё один период, пополните на %s.\n", forecast.TopUp)
+
+	if len(until) == 0 {
+		for _, months := range forecastHorizons {
+			until = append(until, forecast.PaidUntil.AddDate(0, months, 0))
+		}
+	}
+
+	message += "\nЧтобы хватило до:"
+	for _, day := range until {
+		topUp := that.forecastUseCase.TopUpUntil(account, day)
+		switch {
+		case day.Before(now):
+			message += fmt.Sprintf("\n%s — дата уже прошла", day.Format("02.01.2006"))
+		case topUp.IsZero():
+			message += fmt.Sprintf("\n%s — пополнять не нужно", day.Format("02.01.2006"))
+		default:
+			message += fmt.Sprintf("\n%s — пополните на %s", day.Format("02.01.2006"), topUp)
+		}
+	}
+
+	return message
+}
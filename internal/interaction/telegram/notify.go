@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+// NotifyPaymentDue reminds the user to top up the account before the end of the billing period, the top-up QR code
+// and the payment links are attached.
+func (that *Connector) NotifyPaymentDue(ctx context.Context, userID int64, account model.Account, daysLeft int, topUp *usecase.TopUp) error {
+	const template = "⏰ *Пора пополнить баланс*\n\n📱 *Номер аккаунта*: %s\n📅 *Дата оплаты*: %s \\(дней осталось: %d\\)\n💰 *Баланс*: %s\n💳 *К оплате*: %s"
+
+	caption := fmt.Sprintf(
+		template,
+		telegramBot.EscapeMarkdown(account.Number),
+		account.Billing.In(that.location).To.Format("02\\-01\\-2006"),
+		daysLeft,
+		telegramBot.EscapeMarkdown(account.Balance.String()),
+		telegramBot.EscapeMarkdown(topUp.Amount.String()),
+	)
+
+	return that.sendTopUp(ctx, userID, caption, topUp)
+}
+
+// NotifyStatusChanged tells the user that the service of the account was blocked or restored.
+func (that *Connector) NotifyStatusChanged(ctx context.Context, userID int64, account model.Account, previous model.AccountStatus) error {
+	message := fmt.Sprintf(
+		"✅ *Аккаунт %s снова активен*\n\n💰 *Баланс*: %s",
+		telegramBot.EscapeMarkdown(account.Number),
+		telegramBot.EscapeMarkdown(account.Balance.String()),
+	)
+
+	if account.Status == model.AccountStatusBlocked {
+		message = fmt.Sprintf(
+			"🔒 *Аккаунт %s заблокирован*\n\n💰 *Баланс*: %s",
+			telegramBot.EscapeMarkdown(account.Number),
+			telegramBot.EscapeMarkdown(account.Balance.String()),
+		)
+
+		if due := account.AmountDue(); !due.IsZero() {
+			message += fmt.Sprintf("\n💳 *Для восстановления пополните на*: %s", telegramBot.EscapeMarkdown(due.String()))
+		}
+	}
+
+	params := &telegramBot.SendMessageParams{
+		ChatID:    userID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	}
+
+	if account.Status == model.AccountStatusBlocked {
+		params.ReplyMarkup = &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "Обещанный платёж", CallbackData: callbackPromisedAsk + account.Number},
+			}},
+		}
+	}
+
+	_, err := that.tgBot.SendMessage(ctx, params)
+
+	return err
+}
+
+// NotifyPeriodDigest sends the digest of the billing period that has just ended.
+func (that *Connector) NotifyPeriodDigest(ctx context.Context, userID int64, digest usecase.PeriodDigest) error {
+	period, billing := digest.Period.In(that.location), digest.Account.Billing.In(that.location)
+
+	message := fmt.Sprintf(
+		"🗓 *Итоги периода %s — %s*\n\n📱 *Номер аккаунта*: %s",
+		period.From.Format("02\\-01\\-2006"),
+		period.To.Format("02\\-01\\-2006"),
+		telegramBot.EscapeMarkdown(digest.Account.Number),
+	)
+
+	if digest.ChargeObserved {
+		message += fmt.Sprintf("\n💳 *Списано*: %s", telegramBot.EscapeMarkdown(digest.Charged.String()))
+	}
+
+	message += fmt.Sprintf(
+		"\n🧾 *Ожидаемое списание по тарифу*: %s\n💵 *Пополнения*: %s",
+		telegramBot.EscapeMarkdown(digest.Expected.String()),
+		telegramBot.EscapeMarkdown(digest.Paid.String()),
+	)
+
+	for _, payment := range digest.Payments {
+		message += fmt.Sprintf(
+			"\n  %s — %s",
+			payment.PaidAt.In(that.location).Format("02\\-01\\-2006"),
+			telegramBot.EscapeMarkdown(payment.Amount.String()),
+		)
+	}
+
+	message += fmt.Sprintf(
+		"\n💰 *Баланс*: %s\n📅 *Следующая дата оплаты*: %s",
+		telegramBot.EscapeMarkdown(digest.Account.Balance.String()),
+		billing.To.Format("02\\-01\\-2006"),
+	)
+
+	_, err := that.tgBot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:    userID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	})
+
+	return err
+}
+
+// NotifyChargeAnomaly alerts the user that the balance dropped more than the tariff explains.
+func (that *Connector) NotifyChargeAnomaly(ctx context.Context, userID int64, anomaly usecase.ChargeAnomaly) error {
+	message := fmt.Sprintf(
+		"⚠️ *Неожиданное списание*\n\n📱 *Номер аккаунта*: %s\n💰 *Баланс*: %s → %s",
+		telegramBot.EscapeMarkdown(anomaly.Account.Number),
+		telegramBot.EscapeMarkdown(anomaly.Previous.Balance.String()),
+		telegramBot.EscapeMarkdown(anomaly.Current.Balance.String()),
+	)
+
+	if !anomaly.Paid.IsZero() {
+		message += fmt.Sprintf("\n💵 *Пополнения*: %s", telegramBot.EscapeMarkdown(anomaly.Paid.String()))
+	}
+
+	message += fmt.Sprintf(
+		"\n💸 *Списано*: %s\n💳 *Ожидалось по тарифу*: %s\n❗️ *Сверх тарифа*: %s\n\nВозможно, подключена дополнительная услуга или изменился тариф\\. Проверьте личный кабинет\\. Чувствительность: /sensitivity",
+		telegramBot.EscapeMarkdown(anomaly.Charged.String()),
+		telegramBot.EscapeMarkdown(anomaly.Expected.String()),
+		telegramBot.EscapeMarkdown(anomaly.Unexpected.String()),
+	)
+
+	_, err := that.tgBot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:    userID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	})
+
+	return err
+}
+
+// NotifyTariffChanged tells the user about the new tariff of the account and how it changes the forecast.
+func (that *Connector) NotifyTariffChanged(ctx context.Context, userID int64, change usecase.TariffChange) error {
+	message := fmt.Sprintf("🏷 *Тариф изменился*\n\n📱 *Номер аккаунта*: %s", telegramBot.EscapeMarkdown(change.Account.Number))
+
+	if change.PreviousName != "" && change.PreviousName != change.Account.TariffName {
+		message += fmt.Sprintf(
+			"\n📋 *Тарифный план*: %s → %s",
+			telegramBot.EscapeMarkdown(change.PreviousName),
+			telegramBot.EscapeMarkdown(change.Account.TariffName),
+		)
+	}
+
+	message += fmt.Sprintf(
+		"\n💳 *Сумма тарифа*: %s → %s",
+		telegramBot.EscapeMarkdown(change.PreviousAmount.String()),
+		telegramBot.EscapeMarkdown(change.Account.TariffAmount.String()),
+	)
+
+	if change.Forecasted {
+		message += fmt.Sprintf(
+			"\n📈 *Оплачено до*: %s → %s \\(периодов впереди: %d → %d\\)",
+			change.Before.PaidUntil.Format("02\\-01\\-2006"),
+			change.After.PaidUntil.Format("02\\-01\\-2006"),
+			change.Before.PeriodsCovered,
+			change.After.PeriodsCovered,
+		)
+	}
+
+	_, err := that.tgBot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:    userID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	})
+
+	return err
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+func (that *Connector) handlerPay(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerPay", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	account, responseText := selectAccount(user.Accounts, commandArgs(update.Message.Text), "/pay")
+	if account != nil && account.AmountDue().IsZero() {
+		account, responseText = nil, fmt.Sprintf("Баланса аккаунта %s хватает на оплату тарифа, пополнять пока не нужно.", account.Number)
+	}
+
+	if account == nil {
+		_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   responseText,
+		})
+
+		if err != nil {
+			log.Error("Error sending message", "error", err)
+			return
+		}
+
+		return
+	}
+
+	topUp, err := that.topUpUseCase.TopUp(*account)
+	if err != nil {
+		log.Error("Error preparing top-up", "error", err)
+		return
+	}
+
+	caption := fmt.Sprintf(
+		"📱 *Номер аккаунта*: %s\n💳 *К оплате*: %s",
+		telegramBot.EscapeMarkdown(account.Number),
+		telegramBot.EscapeMarkdown(topUp.Amount.String()),
+	)
+
+	if err = that.sendTopUp(ctx, update.Message.Chat.ID, caption, topUp); err != nil {
+		log.Error("Error sending photo", "error", err)
+		return
+	}
+}
+
+// sendTopUp sends the QR code of the top-up with the caption in MarkdownV2 and the payment links as the buttons.
+func (that *Connector) sendTopUp(ctx context.Context, chatID int64, caption string, topUp *usecase.TopUp) error {
+	params := &telegramBot.SendPhotoParams{
+		ChatID:    chatID,
+		Photo:     &models.InputFileUpload{Filename: "topup.png", Data: bytes.NewReader(topUp.QRCode)},
+		Caption:   caption,
+		ParseMode: models.ParseModeMarkdown,
+	}
+
+	if len(topUp.Links) > 0 {
+		keyboard := make([][]models.InlineKeyboardButton, 0, len(topUp.Links))
+		for _, link := range topUp.Links {
+			keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: link.Name, URL: link.URL}})
+		}
+
+		params.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+	}
+
+	_, err := that.tgBot.SendPhoto(ctx, params)
+	return err
+}
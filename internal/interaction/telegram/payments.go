@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const recentPaymentsLimit = 5
+
+func (that *Connector) handlerPayments(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerPayments", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	accounts := user.Accounts
+	if args := commandArgs(update.Message.Text); len(args) > 0 {
+		account, responseText := selectAccount(user.Accounts, args, "/payments")
+		if account == nil {
+			_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   responseText,
+			})
+
+			if err != nil {
+				log.Error("Error sending message", "error", err)
+				return
+			}
+
+			return
+		}
+
+		accounts = []model.Account{*account}
+	}
+
+	message := "*Последние пополнения:*"
+	for _, account := range accounts {
+		payments, err := that.useCase.RecentPayments(ctx, account.ID, recentPaymentsLimit)
+		if err != nil {
+			log.Error("Error listing payments", "error", err)
+			return
+		}
+
+		message += fmt.Sprintf("\n\n📱 *Номер аккаунта*: %s", telegramBot.EscapeMarkdown(account.Number))
+		if len(payments) == 0 {
+			message += "\nПополнений пока нет\\."
+		}
+
+		for _, payment := range payments {
+			message += fmt.Sprintf(
+				"\n%s — %s",
+				payment.PaidAt.In(that.location).Format("02\\-01\\-2006"),
+				telegramBot.EscapeMarkdown(payment.Amount.String()),
+			)
+
+			if payment.Source != "" {
+				message += fmt.Sprintf(" \\(%s\\)", telegramBot.EscapeMarkdown(payment.Source))
+			}
+		}
+	}
+
+	if len(accounts) == 0 {
+		message = "У вас пока нет аккаунтов\\. Сохраните логин и пароль командой /save и запросите баланс командой /balance\\."
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      message,
+		ParseMode: models.ParseModeMarkdown,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
@@ -0,0 +1,119 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+const callbackProfileShowPrefix = "profile:show:"
+
+func (that *Connector) handlerProfile(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerProfile", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	params := &telegramBot.SendMessageParams{ChatID: update.Message.Chat.ID}
+
+	account, responseText := selectAccount(user.Accounts, commandArgs(update.Message.Text), "/profile")
+	if account != nil {
+		var profile *model.Profile
+		if profile, responseText = that.fetchProfile(ctx, log, update.Message.From.ID, account.Number); profile != nil {
+			params.Text = profileMessage(account.Number, profile.Masked())
+			params.ParseMode = models.ParseModeMarkdown
+			params.ReplyMarkup = &models.InlineKeyboardMarkup{
+				InlineKeyboard: [][]models.InlineKeyboardButton{{
+					{Text: "Показать полностью", CallbackData: callbackProfileShowPrefix + account.Number},
+				}},
+			}
+		}
+	}
+
+	if params.Text == "" {
+		params.Text = responseText
+	}
+
+	if _, err = bot.SendMessage(ctx, params); err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
+
+// handlerProfileCallback fetches the profile again and replaces the masked one with it, the unmasked profile is
+// not kept anywhere but in the chat.
+func (that *Connector) handlerProfileCallback(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	log := that.logger.With("method", "handlerProfileCallback", "user_id", query.From.ID)
+
+	if _, err := bot.AnswerCallbackQuery(ctx, &telegramBot.AnswerCallbackQueryParams{CallbackQueryID: query.ID}); err != nil {
+		log.Error("Error answering callback query", "error", err)
+	}
+
+	if query.Message.Message == nil {
+		return
+	}
+
+	params := &telegramBot.EditMessageTextParams{
+		ChatID:    query.Message.Message.Chat.ID,
+		MessageID: query.Message.Message.ID,
+	}
+
+	number := strings.TrimPrefix(query.Data, callbackProfileShowPrefix)
+	profile, responseText := that.fetchProfile(ctx, log, query.From.ID, number)
+	if params.Text = responseText; profile != nil {
+		params.Text = profileMessage(number, *profile)
+		params.ParseMode = models.ParseModeMarkdown
+	}
+
+	if _, err := bot.EditMessageText(ctx, params); err != nil {
+		log.Error("Error editing message", "error", err)
+		return
+	}
+}
+
+// fetchProfile returns the profile of the account or the text explaining why it is not available.
+func (that *Connector) fetchProfile(ctx context.Context, log *slog.Logger, userID int64, number string) (*model.Profile, string) {
+	profile, err := that.useCase.Profile(ctx, userID, number)
+	switch {
+	case err == nil:
+		return profile, ""
+	case errors.Is(err, usecase.ErrAccountNotFound):
+		return nil, "Аккаунт не найден."
+	case errors.Is(err, usecase.ErrNoSession):
+		return nil, "Сначала запросите баланс командой /balance, чтобы войти в личный кабинет."
+	default:
+		log.Error("Error getting profile", "error", err)
+		return nil, "Произошла ошибка при получении профиля. Попробуйте позже."
+	}
+}
+
+func profileMessage(number string, profile model.Profile) string {
+	fields := []struct{ label, value string }{
+		{label: "👤 *Владелец*", value: profile.OwnerName},
+		{label: "🏠 *Адрес*", value: profile.Address},
+		{label: "📶 *Тариф*", value: profile.TariffPlan},
+		{label: "📄 *Договор*", value: profile.ContractNumber},
+		{label: "☎️ *Телефон*", value: profile.Phone},
+	}
+
+	message := fmt.Sprintf("📱 *Профиль аккаунта %s*\n", telegramBot.EscapeMarkdown(number))
+	for _, field := range fields {
+		if field.value != "" {
+			message += fmt.Sprintf("\n%s: %s", field.label, telegramBot.EscapeMarkdown(field.value))
+		}
+	}
+
+	return message
+}
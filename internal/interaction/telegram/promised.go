@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+const (
+	callbackPromisedPrefix  = "promised:"
+	callbackPromisedAsk     = callbackPromisedPrefix + "ask:"
+	callbackPromisedConfirm = callbackPromisedPrefix + "confirm:"
+	callbackPromisedCancel  = callbackPromisedPrefix + "cancel"
+)
+
+func (that *Connector) handlerPromised(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerPromised", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	params := &telegramBot.SendMessageParams{ChatID: update.Message.Chat.ID}
+	account, responseText := selectAccount(user.Accounts, commandArgs(update.Message.Text), "/promised")
+	if params.Text = responseText; account != nil {
+		params = promisedConfirmation(update.Message.Chat.ID, account.Number)
+	}
+
+	if _, err = bot.SendMessage(ctx, params); err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
+
+func (that *Connector) handlerPromisedCallback(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	log := that.logger.With("method", "handlerPromisedCallback", "user_id", query.From.ID)
+
+	if _, err := bot.AnswerCallbackQuery(ctx, &telegramBot.AnswerCallbackQueryParams{CallbackQueryID: query.ID}); err != nil {
+		log.Error("Error answering callback query", "error", err)
+	}
+
+	if query.Message.Message == nil {
+		return
+	}
+
+	chatID := query.Message.Message.Chat.ID
+
+	// The button of a notification asks for the confirmation in a new message, so the notification stays
+	if number, ok := strings.CutPrefix(query.Data, callbackPromisedAsk); ok {
+		if _, err := bot.SendMessage(ctx, promisedConfirmation(chatID, number)); err != nil {
+			log.Error("Error sending message", "error", err)
+		}
+
+		return
+	}
+
+	responseText := "Обещанный платёж не подключен."
+	if number, ok := strings.CutPrefix(query.Data, callbackPromisedConfirm); ok {
+		status, err := that.useCase.RequestPromisedPayment(ctx, query.From.ID, number)
+		responseText = promisedResultText(number, status, err)
+		if err != nil && !errors.Is(err, usecase.ErrAccountNotFound) && !errors.Is(err, usecase.ErrNoSession) {
+			log.Error("Error requesting promised payment", "error", err)
+		}
+	}
+
+	// Replace the confirmation with the result, so the buttons can't be pressed twice
+	_, err := bot.EditMessageText(ctx, &telegramBot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: query.Message.Message.ID,
+		Text:      responseText,
+	})
+
+	if err != nil {
+		log.Error("Error editing message", "error", err, "response_text", responseText)
+		return
+	}
+}
+
+func promisedConfirmation(chatID int64, number string) *telegramBot.SendMessageParams {
+	return &telegramBot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("Подключить обещанный платёж для аккаунта %s? Услуга восстановится на несколько дней, а сумму нужно будет внести до окончания срока.", number),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "Да, подключить", CallbackData: callbackPromisedConfirm + number},
+				{Text: "Отмена", CallbackData: callbackPromisedCancel},
+			}},
+		},
+	}
+}
+
+func promisedResultText(number string, status megaline.PromisedPaymentStatus, err error) string {
+	switch {
+	case errors.Is(err, usecase.ErrAccountNotFound):
+		return "Аккаунт не найден."
+	case errors.Is(err, usecase.ErrNoSession):
+		return "Сначала запросите баланс командой /balance, чтобы войти в личный кабинет."
+	case err != nil:
+		return "Произошла ошибка при подключении обещанного платежа. Попробуйте позже."
+	}
+
+	switch status {
+	case megaline.PromisedPaymentGranted:
+		return fmt.Sprintf("Обещанный платёж для аккаунта %s подключен. Не забудьте пополнить баланс до окончания его срока.", number)
+	case megaline.PromisedPaymentAlreadyUsed:
+		return fmt.Sprintf("Обещанный платёж для аккаунта %s уже использован. Повторно его можно подключить только после пополнения баланса.", number)
+	case megaline.PromisedPaymentIneligible:
+		return fmt.Sprintf("Обещанный платёж для аккаунта %s сейчас недоступен.", number)
+	default:
+		return "Не удалось понять ответ личного кабинета. Проверьте статус обещанного платежа в личном кабинете MegaLine."
+	}
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+
+	telegramBot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// sensitivityDescriptions explain the sensitivities of the unexpected charge alerts to the user.
+var sensitivityDescriptions = map[model.AnomalySensitivity]string{
+	model.AnomalySensitivityOff:    "off — не предупреждать",
+	model.AnomalySensitivityLow:    "low — о списаниях от половины тарифа",
+	model.AnomalySensitivityNormal: "normal — о списаниях от десятой части тарифа",
+	model.AnomalySensitivityHigh:   "high — о любых списаниях",
+}
+
+// handlerSensitivity sets the sensitivity of the unexpected charge alerts for /sensitivity [account] [level],
+// without the level it tells the current one.
+func (that *Connector) handlerSensitivity(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	log := that.logger.With("method", "handlerSensitivity", "user_id", update.Message.From.ID)
+
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Error("Error getting or creating user", "error", err)
+		return
+	}
+
+	var accountArgs []string
+	var sensitivity model.AnomalySensitivity
+	for _, arg := range commandArgs(update.Message.Text) {
+		if parsed, ok := model.ParseAnomalySensitivity(arg); ok {
+			sensitivity = parsed
+			continue
+		}
+
+		accountArgs = append(accountArgs, arg)
+	}
+
+	account, responseText := selectAccount(user.Accounts, accountArgs, "/sensitivity")
+	switch {
+	case account == nil:
+	case sensitivity == "":
+		current := account.AnomalySensitivity
+		if current == "" {
+			current = model.AnomalySensitivityNormal
+		}
+
+		responseText = fmt.Sprintf(
+			"Предупреждения о неожиданных списаниях для аккаунта %s: %s.\n\nИзменить: /sensitivity %s <уровень>\n%s\n%s\n%s\n%s",
+			account.Number,
+			sensitivityDescriptions[current],
+			account.Number,
+			sensitivityDescriptions[model.AnomalySensitivityOff],
+			sensitivityDescriptions[model.AnomalySensitivityLow],
+			sensitivityDescriptions[model.AnomalySensitivityNormal],
+			sensitivityDescriptions[model.AnomalySensitivityHigh],
+		)
+	default:
+		if err = that.useCase.SetAnomalySensitivity(ctx, update.Message.From.ID, account.Number, sensitivity); err != nil {
+			log.Error("Error setting anomaly sensitivity", "error", err)
+			responseText = "Произошла ошибка при сохранении настройки. Попробуйте позже."
+		} else {
+			responseText = fmt.Sprintf("Готово. Предупреждения о неожиданных списаниях для аккаунта %s: %s.", account.Number, sensitivityDescriptions[sensitivity])
+		}
+	}
+
+	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   responseText,
+	})
+
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
+	}
+}
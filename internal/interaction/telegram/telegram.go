@@ -2,57 +2,172 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	telegramBot "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
 	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+	"github.com/aastashov/megalinekg_bot/internal/usecase"
+)
+
+const pollTimeout = time.Minute
+
+type awaitedInput int
+
+const (
+	awaitingNothing awaitedInput = iota
+	awaitingLogin
+	awaitingCaptcha
+)
+
+const (
+	callbackDeletePrefix  = "delete:"
+	callbackDeleteConfirm = callbackDeletePrefix + "confirm"
+	callbackDeleteCancel  = callbackDeletePrefix + "cancel"
 )
 
 type useCase interface {
 	UpdateBalance(ctx context.Context, userID int64) error
+	SolveCaptcha(ctx context.Context, userID int64, answer string) error
+	RecentPayments(ctx context.Context, accountID int, limit int) ([]model.Payment, error)
+	Profile(ctx context.Context, userID int64, accountNumber string) (*model.Profile, error)
+	RequestPromisedPayment(ctx context.Context, userID int64, accountNumber string) (megaline.PromisedPaymentStatus, error)
+	SetDigest(ctx context.Context, userID int64, accountNumber string, enabled bool) error
+	SetAnomalySensitivity(ctx context.Context, userID int64, accountNumber string, sensitivity model.AnomalySensitivity) error
+}
+
+type privacyUseCase interface {
+	Export(ctx context.Context, userID int64) (*usecase.Export, error)
+	DeleteUser(ctx context.Context, userID int64) error
+}
+
+type auditUseCase interface {
+	Record(ctx context.Context, action string, userID int64, details string) error
+	Query(ctx context.Context, action string, userID int64, limit int) ([]model.AuditEvent, error)
+}
+
+type topUpUseCase interface {
+	TopUp(account model.Account) (*usecase.TopUp, error)
+}
+
+type calendarUseCase interface {
+	Calendar(ctx context.Context, userID int64) ([]byte, error)
+	IssueFeedURL(ctx context.Context, userID int64) (string, error)
+	RevokeFeed(ctx context.Context, userID int64) error
+}
+
+type chartUseCase interface {
+	BalanceChart(ctx context.Context, account model.Account, since time.Time) ([]byte, error)
+}
+
+type forecastUseCase interface {
+	Forecast(account model.Account) (usecase.Forecast, bool)
+	TopUpUntil(account model.Account, day time.Time) model.Money
+}
+
+type loginGuard interface {
+	Unlock(ctx context.Context, adminID int64, target string) (int64, error)
 }
 
 type userStorage interface {
 	GetOrCreateByTelegramID(ctx context.Context, userID int64) (*model.User, bool, error)
 	Save(ctx context.Context, user *model.User) error
-	DeleteByTelegramID(ctx context.Context, userID int64) error
 }
 
 type Connector struct {
 	logger *slog.Logger
 	tgBot  *telegramBot.Bot
 
-	userStorage userStorage
-	useCase     useCase
-
-	waitingForLogin map[int64]struct{}
+	userStorage     userStorage
+	useCase         useCase
+	privacyUseCase  privacyUseCase
+	auditUseCase    auditUseCase
+	loginGuard      loginGuard
+	topUpUseCase    topUpUseCase
+	calendarUseCase calendarUseCase
+	chartUseCase    chartUseCase
+	forecastUseCase forecastUseCase
+
+	admins   map[int64]struct{}
+	location *time.Location
+
+	awaitingMu sync.Mutex
+	awaiting   map[int64]awaitedInput
+	commands   map[string]struct{}
+
+	startedAt time.Time
+	lastPoll  atomic.Int64
 }
 
-func NewConnector(logger *slog.Logger, token string, userStorage userStorage, useCase useCase) *Connector {
+func NewConnector(logger *slog.Logger, token string, admins []int64, location *time.Location, userStorage userStorage, useCase useCase, privacyUseCase privacyUseCase, auditUseCase auditUseCase, loginGuard loginGuard, topUpUseCase topUpUseCase, calendarUseCase calendarUseCase, chartUseCase chartUseCase, forecastUseCase forecastUseCase) *Connector {
 	cnt := &Connector{
 		logger:          logger.With("component", "telegram"),
 		userStorage:     userStorage,
 		useCase:         useCase,
-		waitingForLogin: make(map[int64]struct{}),
+		privacyUseCase:  privacyUseCase,
+		auditUseCase:    auditUseCase,
+		loginGuard:      loginGuard,
+		topUpUseCase:    topUpUseCase,
+		calendarUseCase: calendarUseCase,
+		chartUseCase:    chartUseCase,
+		forecastUseCase: forecastUseCase,
+		admins:          make(map[int64]struct{}, len(admins)),
+		location:        location,
+		awaiting:        make(map[int64]awaitedInput),
+		commands:        make(map[string]struct{}),
+		startedAt:       time.Now(),
 	}
 
 	opts := []telegramBot.Option{
 		telegramBot.WithSkipGetMe(),
 		telegramBot.WithDefaultHandler(cnt.handler),
+		telegramBot.WithMiddlewares(cnt.metricsMiddleware),
+		telegramBot.WithHTTPClient(pollTimeout, &pollTracker{client: &http.Client{Timeout: pollTimeout}, onPoll: cnt.markPoll}),
 	}
 
-	b, _ := telegramBot.New(token, opts...)
-	b.RegisterHandler(telegramBot.HandlerTypeMessageText, "/start", telegramBot.MatchTypeExact, cnt.handlerStart)
-	b.RegisterHandler(telegramBot.HandlerTypeMessageText, "/about", telegramBot.MatchTypeExact, cnt.handlerAbout)
-	b.RegisterHandler(telegramBot.HandlerTypeMessageText, "/delete", telegramBot.MatchTypeExact, cnt.handlerDelete)
-	b.RegisterHandler(telegramBot.HandlerTypeMessageText, "/save", telegramBot.MatchTypeExact, cnt.handlerSave)
-	b.RegisterHandler(telegramBot.HandlerTypeMessageText, "/balance", telegramBot.MatchTypeExact, cnt.handlerBalance)
+	for _, admin := range admins {
+		cnt.admins[admin] = struct{}{}
+	}
 
+	b, _ := telegramBot.New(token, opts...)
 	cnt.tgBot = b
+
+	cnt.registerCommand("/start", cnt.handlerStart)
+	cnt.registerCommand("/about", cnt.handlerAbout)
+	cnt.registerCommand("/delete", cnt.handlerDelete)
+	cnt.registerCommand("/save", cnt.handlerSave)
+	cnt.registerCommand("/balance", cnt.handlerBalance)
+	cnt.registerCommand("/export", cnt.handlerExport)
+	cnt.registerCommand("/account", cnt.handlerAccount)
+	cnt.registerCommand("/payments", cnt.handlerPayments)
+	cnt.registerCommand("/profile", cnt.handlerProfile)
+	cnt.registerCommand("/promised", cnt.handlerPromised)
+	cnt.registerCommand("/pay", cnt.handlerPay)
+	cnt.registerCommand("/calendar", cnt.handlerCalendar)
+	cnt.registerCommand("/chart", cnt.handlerChart)
+	cnt.registerCommand("/forecast", cnt.handlerForecast)
+	cnt.registerCommand("/digest", cnt.handlerDigest)
+	cnt.registerCommand("/sensitivity", cnt.handlerSensitivity)
+
+	// Admin commands
+	cnt.registerCommand("/audit", cnt.handlerAudit, cnt.adminOnly)
+	cnt.registerCommand("/unlock", cnt.handlerUnlock, cnt.adminOnly)
+
+	b.RegisterHandler(telegramBot.HandlerTypeCallbackQueryData, callbackDeletePrefix, telegramBot.MatchTypePrefix, cnt.handlerDeleteCallback)
+	b.RegisterHandler(telegramBot.HandlerTypeCallbackQueryData, callbackProfileShowPrefix, telegramBot.MatchTypePrefix, cnt.handlerProfileCallback)
+	b.RegisterHandler(telegramBot.HandlerTypeCallbackQueryData, callbackPromisedPrefix, telegramBot.MatchTypePrefix, cnt.handlerPromisedCallback)
+
 	return cnt
 }
 
@@ -60,6 +175,106 @@ func (that *Connector) Start(ctx context.Context) {
 	that.tgBot.Start(ctx)
 }
 
+// LastPoll returns the time of the last successful request for updates. Until the first poll
+// completes, the start time of the connector is returned, so a fresh instance is not reported stale.
+func (that *Connector) LastPoll() time.Time {
+	if lastPoll := that.lastPoll.Load(); lastPoll != 0 {
+		return time.Unix(0, lastPoll)
+	}
+
+	return that.startedAt
+}
+
+func (that *Connector) markPoll() {
+	that.lastPoll.Store(time.Now().UnixNano())
+}
+
+// await marks the next message of the user as the input for the previous command, e.g. the login and password
+// for /save.
+func (that *Connector) await(userID int64, input awaitedInput) {
+	that.awaitingMu.Lock()
+	that.awaiting[userID] = input
+	that.awaitingMu.Unlock()
+}
+
+// takeAwaited returns the input expected from the user and stops waiting for it.
+func (that *Connector) takeAwaited(userID int64) awaitedInput {
+	that.awaitingMu.Lock()
+	defer that.awaitingMu.Unlock()
+
+	input := that.awaiting[userID]
+	delete(that.awaiting, userID)
+
+	return input
+}
+
+// registerCommand registers the handler of the command. The command matches with and without the arguments,
+// e.g. both "/audit" and "/audit 12345".
+func (that *Connector) registerCommand(command string, handler telegramBot.HandlerFunc, middlewares ...telegramBot.Middleware) {
+	that.commands[command] = struct{}{}
+	that.tgBot.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.Message != nil && commandName(update.Message.Text) == command
+	}, handler, middlewares...)
+}
+
+// commandName returns the command of the message text without the arguments and the bot mention,
+// e.g. "/audit" for "/audit@MegaLineBot 12345".
+func commandName(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return ""
+	}
+
+	command, _, _ := strings.Cut(fields[0], "@")
+	return command
+}
+
+// commandArgs returns the arguments of the command in the message text.
+func commandArgs(text string) []string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields[1:]
+}
+
+// metricsMiddleware counts handled updates by command. Anything that is not a registered command is
+// counted as "text" to keep the label cardinality bounded.
+func (that *Connector) metricsMiddleware(next telegramBot.HandlerFunc) telegramBot.HandlerFunc {
+	return func(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+		command := "other"
+		if update.CallbackQuery != nil {
+			command = "callback"
+		}
+
+		if update.Message != nil {
+			command = "text"
+			if _, ok := that.commands[commandName(update.Message.Text)]; ok {
+				command = commandName(update.Message.Text)
+			}
+		}
+
+		metrics.CommandsHandled.WithLabelValues(command).Inc()
+		next(ctx, bot, update)
+	}
+}
+
+// pollTracker wraps the HTTP client of the bot and reports every successful getUpdates request.
+type pollTracker struct {
+	client *http.Client
+	onPoll func()
+}
+
+func (t *pollTracker) Do(req *http.Request) (*http.Response, error) {
+	resp, err := t.client.Do(req)
+	if err == nil && resp.StatusCode == http.StatusOK && strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		t.onPoll()
+	}
+
+	return resp, err
+}
+
 func (that *Connector) handlerStart(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
 	log := that.logger.With("method", "handlerStart", "user_id", update.Message.From.ID)
 
@@ -94,6 +309,7 @@ func (that *Connector) handlerAbout(ctx context.Context, bot *telegramBot.Bot, u
 ✨ Я уважаю вашу конфиденциальность и использую данные только для того, чтобы напоминать вам о балансе\.
 🛡️ Храню только ту информацию, которая необходима для работы, и ничего лишнего\.
 💻 Мой код открыт для всех и доступен на GitHub: [GitHub](https://github\.com/aastashov/megalinekg_bot)\.
+📦 Чтобы получить все данные, которые я о вас храню, используйте команду \/export\.
 🧹 Если захотите удалить свои данные, просто используйте команду \/delete — всё удалится полностью\.
 
 📥 Чтобы сохранить логин и пароль от личного кабинета, используйте команду \/save\. Эти данные будут храниться только для получения актуального баланса и расчетного периода для напоминания\.
@@ -117,20 +333,60 @@ func (that *Connector) handlerAbout(ctx context.Context, bot *telegramBot.Bot, u
 func (that *Connector) handlerDelete(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
 	log := that.logger.With("method", "handlerDelete", "user_id", update.Message.From.ID)
 
-	responseText := "Ваши данные удалены. Для начала работы заново, напишите /start."
+	_, err := bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   "Вы уверены? Будут удалены ваш логин и пароль, сессия, аккаунты и история баланса. Это действие нельзя отменить.",
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "Да, удалить", CallbackData: callbackDeleteConfirm},
+				{Text: "Отмена", CallbackData: callbackDeleteCancel},
+			}},
+		},
+	})
 
-	if err := that.userStorage.DeleteByTelegramID(ctx, update.Message.From.ID); err != nil {
-		log.Error("Error deleting user", "error", err)
-		responseText = "Произошла ошибка при удалении данных. Попробуйте позже."
+	if err != nil {
+		log.Error("Error sending message", "error", err)
+		return
 	}
+}
 
-	_, err := bot.SendMessage(ctx, &telegramBot.SendMessageParams{
-		ChatID: update.Message.Chat.ID,
-		Text:   responseText,
+func (that *Connector) handlerDeleteCallback(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	log := that.logger.With("method", "handlerDeleteCallback", "user_id", query.From.ID)
+
+	if _, err := bot.AnswerCallbackQuery(ctx, &telegramBot.AnswerCallbackQueryParams{CallbackQueryID: query.ID}); err != nil {
+		log.Error("Error answering callback query", "error", err)
+	}
+
+	responseText := "Удаление отменено."
+	if query.Data == callbackDeleteConfirm {
+		responseText = "Ваши данные удалены. Для начала работы заново, напишите /start."
+
+		that.takeAwaited(query.From.ID)
+
+		if err := that.privacyUseCase.DeleteUser(ctx, query.From.ID); err != nil {
+			log.Error("Error deleting user", "error", err)
+
+			responseText = "Произошла ошибка при удалении данных. Попробуйте позже."
+			if errors.Is(err, storage.ErrNotFound) {
+				responseText = "У меня нет ваших данных."
+			}
+		}
+	}
+
+	if query.Message.Message == nil {
+		return
+	}
+
+	// Replace the confirmation with the result, so the buttons can't be pressed twice
+	_, err := bot.EditMessageText(ctx, &telegramBot.EditMessageTextParams{
+		ChatID:    query.Message.Message.Chat.ID,
+		MessageID: query.Message.Message.ID,
+		Text:      responseText,
 	})
 
 	if err != nil {
-		log.Error("Error sending message", "error", err, "response_text", responseText)
+		log.Error("Error editing message", "error", err, "response_text", responseText)
 		return
 	}
 }
@@ -149,16 +405,44 @@ func (that *Connector) handlerSave(ctx context.Context, bot *telegramBot.Bot, up
 	}
 
 	// Set user as waiting for login
-	that.waitingForLogin[update.Message.From.ID] = struct{}{}
+	that.await(update.Message.From.ID, awaitingLogin)
 }
 
 func (that *Connector) handlerBalance(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
-	log := that.logger.With("method", "handlerBalance", "user_id", update.Message.From.ID)
+	err := that.useCase.UpdateBalance(ctx, update.Message.From.ID)
+	that.sendBalance(ctx, bot, update.Message.Chat.ID, update.Message.From.ID, err)
+}
 
-	if err := that.useCase.UpdateBalance(ctx, update.Message.From.ID); err != nil {
-		_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "Произошла ошибка при получении баланса. Попробуйте позже.",
+// sendBalance sends the accounts of the user after the refresh, or the explanation why the refresh failed.
+func (that *Connector) sendBalance(ctx context.Context, bot *telegramBot.Bot, chatID, userID int64, updateErr error) {
+	log := that.logger.With("method", "sendBalance", "user_id", userID)
+
+	if updateErr != nil {
+		var captchaErr *usecase.CaptchaRequiredError
+		if errors.As(updateErr, &captchaErr) {
+			that.sendCaptcha(ctx, bot, chatID, userID, captchaErr.Image)
+			return
+		}
+
+		responseText := "Произошла ошибка при получении баланса. Попробуйте позже."
+
+		var lockedErr *usecase.LoginLockedError
+		switch {
+		case errors.As(updateErr, &lockedErr):
+			responseText = fmt.Sprintf("Слишком много неудачных попыток входа в личный кабинет. Попробуйте после %s.", lockedErr.Until.Format("02.01.2006 15:04"))
+		case errors.Is(updateErr, usecase.ErrBadCredentials):
+			responseText = "MegaLine не принял логин или пароль. Проверьте их и сохраните заново командой /save."
+		case errors.Is(updateErr, usecase.ErrMegaLineBlocked):
+			responseText = "MegaLine временно заблокировал вход в личный кабинет. Попробуйте позже."
+		case errors.Is(updateErr, usecase.ErrMegaLineMaintenance):
+			responseText = "В личном кабинете MegaLine идут технические работы. Попробуйте позже."
+		case errors.Is(updateErr, usecase.ErrNoPendingCaptcha):
+			responseText = "Время на ввод кода истекло. Запросите баланс заново командой /balance."
+		}
+
+		_, err := bot.SendMessage(ctx, &telegramBot.SendMessageParams{
+			ChatID: chatID,
+			Text:   responseText,
 		})
 
 		if err != nil {
@@ -169,7 +453,7 @@ func (that *Connector) handlerBalance(ctx context.Context, bot *telegramBot.Bot,
 		return
 	}
 
-	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, update.Message.From.ID)
+	user, _, err := that.userStorage.GetOrCreateByTelegramID(ctx, userID)
 	if err != nil {
 		log.Error("Error getting or creating user", "error", err)
 		return
@@ -181,16 +465,23 @@ func (that *Connector) handlerBalance(ctx context.Context, bot *telegramBot.Bot,
 	}
 
 	sep := "\n\n"
-	const template = "%s📱 *Номер аккаунта*: %s\n💰 *Баланс*: %v KGS\n📅 *Дата оплаты*: %s\n💳 *Сумма тарифа*: %d KGS"
+	const template = "%s📱 *Номер аккаунта*: %s\n💰 *Баланс*: %s\n📅 *Дата оплаты*: %s \\(дней осталось: %d\\)\n💳 *Сумма тарифа*: %s"
 
+	now := time.Now()
 	for _, account := range user.Accounts {
-		message += fmt.Sprintf(template, sep, account.Number, account.Balance, account.BillingTo.Format("02\\-01\\-2006"), account.TariffAmount)
+		balance := telegramBot.EscapeMarkdown(account.Balance.String())
+		tariffAmount := telegramBot.EscapeMarkdown(account.TariffAmount.String())
+		billing := account.Billing.In(that.location)
+		message += fmt.Sprintf(template, sep, account.Number, balance, billing.To.Format("02\\-01\\-2006"), billing.DaysRemaining(now), tariffAmount)
+		if forecast, ok := that.forecastUseCase.Forecast(account); ok {
+			message += fmt.Sprintf("\n📈 *Оплачено до*: %s \\(периодов впереди: %d\\)", forecast.PaidUntil.Format("02\\-01\\-2006"), forecast.PeriodsCovered)
+		}
 	}
 
 	message = strings.ReplaceAll(message, ".", "\\.")
 
 	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
-		ChatID:    update.Message.Chat.ID,
+		ChatID:    chatID,
 		Text:      message,
 		ParseMode: models.ParseModeMarkdown,
 	})
@@ -202,21 +493,25 @@ func (that *Connector) handlerBalance(ctx context.Context, bot *telegramBot.Bot,
 }
 
 func (that *Connector) handler(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
+	if update.Message == nil {
+		// Nothing to do with the updates other than messages, e.g. the callbacks of outdated buttons
+		return
+	}
+
 	log := that.logger.With("method", "handler", "user_id", update.Message.From.ID)
 	log.Info("Handling message", "text", update.Message.Text)
 
-	if _, ok := that.waitingForLogin[update.Message.From.ID]; ok {
+	switch that.takeAwaited(update.Message.From.ID) {
+	case awaitingLogin:
 		that.handleWaitingForLogin(ctx, bot, update)
-		return
+	case awaitingCaptcha:
+		that.handleWaitingForCaptcha(ctx, bot, update)
 	}
 }
 
 func (that *Connector) handleWaitingForLogin(ctx context.Context, bot *telegramBot.Bot, update *models.Update) {
 	log := that.logger.With("method", "handleWaitingForLogin", "user_id", update.Message.From.ID)
 
-	// Handle login
-	delete(that.waitingForLogin, update.Message.From.ID)
-
 	login, password := "", ""
 	// Parse login and password
 	parts := strings.Split(strings.TrimSpace(update.Message.Text), " ")
@@ -244,13 +539,22 @@ func (that *Connector) handleWaitingForLogin(ctx context.Context, bot *telegramB
 		return
 	}
 
+	action := model.AuditActionCredentialsSaved
+	if user.AuthUsername != "" {
+		action = model.AuditActionCredentialsChanged
+	}
+
+	// The session belongs to the previous credentials, the next refresh logs in with the new ones
 	user.AuthUsername = login
 	user.AuthPassword = password
+	user.Session = ""
 	if err = that.userStorage.Save(ctx, user); err != nil {
 		log.Error("Error saving user", "error", err)
 		return
 	}
 
+	_ = that.auditUseCase.Record(ctx, action, update.Message.From.ID, "")
+
 	_, err = bot.SendMessage(ctx, &telegramBot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
 		Text:   "Данные сохранены. Теперь вы можете получать актуальный баланс.",
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "megalinebot"
+
+var (
+	// CommandsHandled counts Telegram updates handled by the bot, labeled by command.
+	CommandsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "telegram",
+		Name:      "commands_handled_total",
+		Help:      "Number of Telegram commands handled by the bot.",
+	}, []string{"command"})
+
+	// MegaLineRequestDuration observes the latency of the requests to bill.mega.kg.
+	MegaLineRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "megaline",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests to the MegaLine personal cabinet.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"page", "method"})
+
+	// MegaLineRequests counts requests to bill.mega.kg, labeled by the HTTP status code or "error".
+	MegaLineRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "megaline",
+		Name:      "requests_total",
+		Help:      "Number of requests to the MegaLine personal cabinet by status.",
+	}, []string{"page", "method", "status"})
+
+	// ParseFailures counts fields of the MegaLine pages that could not be parsed.
+	ParseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "megaline",
+		Name:      "parse_failures_total",
+		Help:      "Number of MegaLine page fields that failed to parse.",
+	}, []string{"field"})
+
+	// RefreshResults counts balance refreshes, labeled by the result.
+	RefreshResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "refresh_total",
+		Help:      "Number of balance refreshes by result.",
+	}, []string{"result"})
+
+	// RefreshDuration observes the duration of a full balance refresh for a user.
+	RefreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "refresh_duration_seconds",
+		Help:      "Duration of a full balance refresh for a user.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 40},
+	})
+
+	// AccountsDiscovered counts the accounts found by the logins for the first time.
+	AccountsDiscovered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "accounts_discovered_total",
+		Help:      "Number of accounts found by the logins for the first time.",
+	})
+
+	// PaymentsDetected counts the payments stored for the first time.
+	PaymentsDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "payments_detected_total",
+		Help:      "Number of payments detected by the balance refreshes.",
+	})
+
+	// StatusChanges counts the changes of the account status, labeled by the new status.
+	StatusChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "balance",
+		Name:      "status_changes_total",
+		Help:      "Number of account status changes by the new status.",
+	}, []string{"status"})
+
+	// LoginFailures counts the failed logins to bill.mega.kg, labeled by the reason.
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "megaline",
+		Name:      "login_failures_total",
+		Help:      "Number of failed logins to the MegaLine personal cabinet by reason.",
+	}, []string{"reason"})
+
+	// EventsHandled counts the domain events handled by the subscribers, labeled by the result.
+	EventsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "handled_total",
+		Help:      "Number of domain events handled by the subscribers by result.",
+	}, []string{"event", "subscriber", "result"})
+
+	// EventsDropped counts the domain events dropped because the queue of the subscriber was full.
+	EventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "events",
+		Name:      "dropped_total",
+		Help:      "Number of domain events dropped because the subscriber queue was full.",
+	}, []string{"event", "subscriber"})
+)
@@ -5,9 +5,29 @@ import "time"
 type Account struct {
 	ID           int `gorm:"primaryKey"`
 	UserID       int
-	Number       string `gorm:"unique"`
-	BillingFrom  time.Time
-	BillingTo    time.Time
-	TariffAmount int
-	Balance      float64
+	Number       string        `gorm:"unique"`
+	Billing      BillingPeriod `gorm:"embedded;embeddedPrefix:billing_"`
+	TariffAmount Money         `gorm:"embedded;embeddedPrefix:tariff_"`
+	Balance      Money         `gorm:"embedded;embeddedPrefix:balance_"`
+	Status       AccountStatus
+	// TariffName is the name of the tariff plan as shown in the personal cabinet.
+	TariffName string
+	// Info is every row of the account information as shown in the personal cabinet.
+	Info AccountInfo
+	// RemindedFor is the end of the billing period the payment reminder was last sent for.
+	RemindedFor time.Time
+	// DigestEnabled is whether the owner opted in to the digest of the period sent when the next one starts.
+	DigestEnabled bool
+	// AnomalySensitivity is how large the unexpected charge must be to alert the owner, empty means normal.
+	AnomalySensitivity AnomalySensitivity
+}
+
+// AmountDue returns the amount to pay to cover the tariff of the next period, zero if the balance is enough.
+func (a Account) AmountDue() Money {
+	due := a.TariffAmount.Sub(a.Balance)
+	if due.IsNegative() {
+		return Money{Currency: due.Currency}
+	}
+
+	return due
 }
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// AccountField is a single row of the account information in the personal cabinet.
+type AccountField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// AccountInfo is every row of the account information in the order of the personal cabinet. It is stored as JSON,
+// so the new rows are kept without changes to the schema.
+type AccountInfo []AccountField
+
+// Get returns the value of the key.
+func (info AccountInfo) Get(key string) (string, bool) {
+	for _, field := range info {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Set replaces the value of the key in place or appends the key.
+func (info *AccountInfo) Set(key, value string) {
+	for i := range *info {
+		if (*info)[i].Key == key {
+			(*info)[i].Value = value
+			return
+		}
+	}
+
+	*info = append(*info, AccountField{Key: key, Value: value})
+}
+
+func (info AccountInfo) Value() (driver.Value, error) {
+	if info == nil {
+		return nil, nil
+	}
+
+	value, err := json.Marshal(info)
+	return string(value), err
+}
+
+func (info *AccountInfo) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*info = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, info)
+	case string:
+		return json.Unmarshal([]byte(value), info)
+	default:
+		return fmt.Errorf("unsupported account info type %T", value)
+	}
+}
+
+func (AccountInfo) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType stores the info as JSONB in Postgres and as text in SQLite.
+func (AccountInfo) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+
+	return "text"
+}
@@ -0,0 +1,10 @@
+package model
+
+// AccountStatus is the state of the service of the account.
+type AccountStatus string
+
+const (
+	AccountStatusActive  AccountStatus = "active"
+	AccountStatusBlocked AccountStatus = "blocked"
+	AccountStatusUnknown AccountStatus = "unknown"
+)
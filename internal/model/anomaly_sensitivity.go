@@ -0,0 +1,39 @@
+package model
+
+// AnomalySensitivity is how large the unexpected charge of the account must be for the owner to be alerted.
+type AnomalySensitivity string
+
+const (
+	// AnomalySensitivityOff disables the alerts.
+	AnomalySensitivityOff AnomalySensitivity = "off"
+	// AnomalySensitivityLow alerts about the charges of at least half the tariff.
+	AnomalySensitivityLow AnomalySensitivity = "low"
+	// AnomalySensitivityNormal alerts about the charges of at least a tenth of the tariff. It is the default.
+	AnomalySensitivityNormal AnomalySensitivity = "normal"
+	// AnomalySensitivityHigh alerts about any charge.
+	AnomalySensitivityHigh AnomalySensitivity = "high"
+)
+
+// ParseAnomalySensitivity returns the sensitivity by its name, false if there is no such sensitivity.
+func ParseAnomalySensitivity(name string) (AnomalySensitivity, bool) {
+	switch sensitivity := AnomalySensitivity(name); sensitivity {
+	case AnomalySensitivityOff, AnomalySensitivityLow, AnomalySensitivityNormal, AnomalySensitivityHigh:
+		return sensitivity, true
+	default:
+		return "", false
+	}
+}
+
+// Threshold returns the smallest unexpected charge alerted about for the tariff, false if the alerts are off.
+func (s AnomalySensitivity) Threshold(tariff Money) (Money, bool) {
+	switch s {
+	case AnomalySensitivityOff:
+		return Money{}, false
+	case AnomalySensitivityLow:
+		return Money{Tyiyn: tariff.Tyiyn / 2, Currency: tariff.Currency}, true
+	case AnomalySensitivityHigh:
+		return Money{Tyiyn: 1, Currency: tariff.Currency}, true
+	default:
+		return Money{Tyiyn: max(tariff.Tyiyn/10, 1), Currency: tariff.Currency}, true
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+const (
+	AuditActionCredentialsSaved     = "credentials.saved"
+	AuditActionCredentialsChanged   = "credentials.changed"
+	AuditActionLoginSucceeded       = "login.succeeded"
+	AuditActionLoginFailed          = "login.failed"
+	AuditActionLoginLocked          = "login.locked"
+	AuditActionDataExported         = "data.exported"
+	AuditActionUserDeleted          = "user.deleted"
+	AuditActionPromisedPayment      = "promised_payment.requested"
+	AuditActionCalendarTokenIssued  = "calendar.token_issued"
+	AuditActionCalendarTokenRevoked = "calendar.token_revoked"
+	AuditActionAdminAuditQueried    = "admin.audit_queried"
+	AuditActionAdminLoginUnlocked   = "admin.login_unlocked"
+)
+
+// AuditEvent is an append-only record of a security-relevant action. The actor is stored as a keyed hash of the
+// Telegram ID, so the record proves the action happened without keeping personal data.
+type AuditEvent struct {
+	ID        int       `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	Action    string    `gorm:"index"`
+	ActorHash string    `gorm:"index"`
+	// Details is a short free-form note, e.g. the reason of a failed login. It must not contain personal data.
+	Details string
+}
+
+// AuditFilter narrows down the audit events returned by a query, the zero value matches all events.
+type AuditFilter struct {
+	Action    string
+	ActorHash string
+	Limit     int
+}
@@ -0,0 +1,99 @@
+package model
+
+import "time"
+
+// BillingPeriod is the range of calendar days from From to To inclusive the tariff is paid for. The bounds are
+// the midnights of the days in the location of the personal cabinet. The database keeps the instants but not the
+// location, so the period loaded from it has to be put back into the location with In.
+type BillingPeriod struct {
+	From time.Time
+	To   time.Time
+}
+
+// In returns the period with the bounds in the location.
+func (p BillingPeriod) In(loc *time.Location) BillingPeriod {
+	return BillingPeriod{From: p.From.In(loc), To: p.To.In(loc)}
+}
+
+func (p BillingPeriod) IsZero() bool {
+	return p.From.IsZero() && p.To.IsZero()
+}
+
+// Equal reports whether the periods have the same bounds regardless of their location.
+func (p BillingPeriod) Equal(other BillingPeriod) bool {
+	return p.From.Equal(other.From) && p.To.Equal(other.To)
+}
+
+// Days returns the length of the period in days, both bounds included.
+func (p BillingPeriod) Days() int {
+	if p.IsZero() {
+		return 0
+	}
+
+	return p.daysBetween(p.From, p.To) + 1
+}
+
+// Contains reports whether the day of the moment in the location of the period is within the period.
+func (p BillingPeriod) Contains(moment time.Time) bool {
+	if p.IsZero() {
+		return false
+	}
+
+	return p.daysBetween(p.From, moment) >= 0 && p.daysBetween(moment, p.To) >= 0
+}
+
+// DaysRemaining returns the number of days left in the period at the moment, the current day included. It is zero
+// once the period is over and the whole length before it starts.
+func (p BillingPeriod) DaysRemaining(moment time.Time) int {
+	if p.IsZero() {
+		return 0
+	}
+
+	return max(min(p.daysBetween(moment, p.To)+1, p.Days()), 0)
+}
+
+// Next returns the period following this one. The periods of whole months, e.g. from the 5th to the 4th, are
+// followed by the periods of the same number of months, the others by the periods of the same number of days.
+// The period starting on a day the month ends before, e.g. on the 31st, ends on the last day of the month instead,
+// so 31 Jan – 28 Feb is a month as well and is followed by 1 Mar – 31 Mar.
+func (p BillingPeriod) Next() BillingPeriod {
+	start := p.To.AddDate(0, 0, 1)
+
+	switch {
+	case start.Day() == p.From.Day():
+		return BillingPeriod{From: start, To: endOfMonths(start, monthsBetween(p.From, start))}
+	case start.Day() == 1 && p.From.Day() > p.To.Day():
+		return BillingPeriod{From: start, To: endOfMonths(start, monthsBetween(p.From, p.To))}
+	default:
+		return BillingPeriod{From: start, To: start.AddDate(0, 0, p.Days()-1)}
+	}
+}
+
+// monthsBetween returns the number of the calendar months from the month of a to the month of b.
+func monthsBetween(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+}
+
+// endOfMonths returns the last day of the period of the months starting on the day: the day before the same day of
+// the month the months later, or the last day of that month if it is shorter.
+func endOfMonths(start time.Time, months int) time.Time {
+	year, month, day := start.Date()
+	if lastDay := time.Date(year, month+time.Month(months)+1, 0, 0, 0, 0, 0, start.Location()).Day(); day > lastDay {
+		return time.Date(year, month+time.Month(months), lastDay, 0, 0, 0, 0, start.Location())
+	}
+
+	return time.Date(year, month+time.Month(months), day-1, 0, 0, 0, 0, start.Location())
+}
+
+// daysBetween returns the number of calendar days from the day of a to the day of b in the location of the period.
+func (p BillingPeriod) daysBetween(a, b time.Time) int {
+	loc := p.To.Location()
+	return int(calendarDay(b, loc).Sub(calendarDay(a, loc)).Hours() / 24)
+}
+
+// calendarDay returns the day of the moment in the location as UTC midnight, so that the days can be subtracted
+// regardless of the daylight saving time.
+func calendarDay(moment time.Time, loc *time.Location) time.Time {
+	year, month, day := moment.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+var bishkek = mustLoadLocation("Asia/Bishkek")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// day returns the midnight of the day in Bishkek.
+func day(year int, month time.Month, dayOfMonth int) time.Time {
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, bishkek)
+}
+
+func period(from, to time.Time) BillingPeriod {
+	return BillingPeriod{From: from, To: to}
+}
+
+func TestBillingPeriodDays(t *testing.T) {
+	berlin := mustLoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		name   string
+		period BillingPeriod
+		want   int
+	}{
+		{name: "month", period: period(day(2026, 10, 5), day(2026, 11, 4)), want: 31},
+		{name: "single day", period: period(day(2026, 10, 5), day(2026, 10, 5)), want: 1},
+		{name: "leap february", period: period(day(2028, 2, 1), day(2028, 2, 29)), want: 29},
+		{
+			name:   "daylight saving time switch",
+			period: period(time.Date(2026, 3, 20, 0, 0, 0, 0, berlin), time.Date(2026, 4, 19, 0, 0, 0, 0, berlin)),
+			want:   31,
+		},
+		{name: "zero", period: BillingPeriod{}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.period.Days(); got != tt.want {
+				t.Errorf("Days() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillingPeriodContains(t *testing.T) {
+	october := period(day(2026, 10, 5), day(2026, 11, 4))
+
+	tests := []struct {
+		name   string
+		period BillingPeriod
+		moment time.Time
+		want   bool
+	}{
+		{name: "first day", period: october, moment: day(2026, 10, 5), want: true},
+		{name: "last minute of the last day", period: october, moment: day(2026, 11, 4).Add(24*time.Hour - time.Minute), want: true},
+		{name: "day before", period: october, moment: day(2026, 10, 4).Add(23 * time.Hour), want: false},
+		{name: "day after", period: october, moment: day(2026, 11, 5), want: false},
+		// 18:00 UTC on the 4th of November is already the 5th in Bishkek
+		{name: "moment in UTC", period: october, moment: time.Date(2026, 11, 4, 17, 0, 0, 0, time.UTC), want: true},
+		{name: "moment in UTC on the next day", period: october, moment: time.Date(2026, 11, 4, 18, 0, 0, 0, time.UTC), want: false},
+		{name: "zero", period: BillingPeriod{}, moment: day(2026, 10, 5), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.period.Contains(tt.moment); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.moment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillingPeriodDaysRemaining(t *testing.T) {
+	october := period(day(2026, 10, 5), day(2026, 11, 4))
+
+	tests := []struct {
+		name   string
+		period BillingPeriod
+		moment time.Time
+		want   int
+	}{
+		{name: "first day", period: october, moment: day(2026, 10, 5).Add(12 * time.Hour), want: 31},
+		{name: "middle", period: october, moment: day(2026, 10, 20), want: 16},
+		{name: "last day", period: october, moment: day(2026, 11, 4).Add(23 * time.Hour), want: 1},
+		{name: "over", period: october, moment: day(2026, 11, 5), want: 0},
+		{name: "long over", period: october, moment: day(2027, 1, 1), want: 0},
+		{name: "not started", period: october, moment: day(2026, 9, 1), want: 31},
+		{name: "zero", period: BillingPeriod{}, moment: day(2026, 10, 5), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.period.DaysRemaining(tt.moment); got != tt.want {
+				t.Errorf("DaysRemaining(%s) = %d, want %d", tt.moment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillingPeriodNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		period BillingPeriod
+		want   BillingPeriod
+	}{
+		{
+			name:   "month",
+			period: period(day(2026, 10, 5), day(2026, 11, 4)),
+			want:   period(day(2026, 11, 5), day(2026, 12, 4)),
+		},
+		{
+			name:   "calendar month",
+			period: period(day(2026, 3, 1), day(2026, 3, 31)),
+			want:   period(day(2026, 4, 1), day(2026, 4, 30)),
+		},
+		{
+			name:   "month ending on the last day of february",
+			period: period(day(2027, 1, 31), day(2027, 2, 28)),
+			want:   period(day(2027, 3, 1), day(2027, 3, 31)),
+		},
+		{
+			name:   "month starting on the 31st before february",
+			period: period(day(2026, 12, 31), day(2027, 1, 30)),
+			want:   period(day(2027, 1, 31), day(2027, 2, 28)),
+		},
+		{
+			name:   "month starting on the 30th before a leap february",
+			period: period(day(2027, 12, 30), day(2028, 1, 29)),
+			want:   period(day(2028, 1, 30), day(2028, 2, 29)),
+		},
+		{
+			name:   "year end",
+			period: period(day(2026, 12, 15), day(2027, 1, 14)),
+			want:   period(day(2027, 1, 15), day(2027, 2, 14)),
+		},
+		{
+			name:   "three months",
+			period: period(day(2026, 1, 10), day(2026, 4, 9)),
+			want:   period(day(2026, 4, 10), day(2026, 7, 9)),
+		},
+		{
+			name:   "days",
+			period: period(day(2026, 10, 1), day(2026, 10, 30)),
+			want:   period(day(2026, 10, 31), day(2026, 11, 29)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.period.Next(); !got.Equal(tt.want) {
+				t.Errorf("Next() = %s – %s, want %s – %s", got.From, got.To, tt.want.From, tt.want.To)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// LoginAttempt counts the failed MegaLine logins of a Telegram user or of a MegaLine login within the current
+// window. The subject is a keyed hash, so the table doesn't keep the logins in clear text.
+type LoginAttempt struct {
+	Subject     string `gorm:"primaryKey"`
+	Failures    int
+	WindowStart time.Time
+	LockedUntil time.Time
+}
+
+// IsLocked reports whether the logins are blocked at the given time.
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return now.Before(a.LockedUntil)
+}
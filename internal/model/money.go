@@ -0,0 +1,174 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CurrencyKGS is the currency of all the amounts in the MegaLine personal cabinet.
+const CurrencyKGS = "KGS"
+
+// Money is an amount in integer tyiyn, the hundredth of a som, with the currency. The zero value is zero in any
+// currency, so it can be added to and compared with any amount.
+type Money struct {
+	Tyiyn    int64  `json:"tyiyn"`
+	Currency string `json:"currency"`
+}
+
+// Som returns the amount of whole soms in KGS.
+func Som(som int64) Money {
+	return Money{Tyiyn: som * 100, Currency: CurrencyKGS}
+}
+
+// Tyiyn returns the amount of tyiyns in KGS.
+func Tyiyn(tyiyn int64) Money {
+	return Money{Tyiyn: tyiyn, Currency: CurrencyKGS}
+}
+
+// ParseMoney parses an amount in KGS as shown in the personal cabinet, e.g. "1 234,56 сом", "-12.5 сом" or "950".
+func ParseMoney(value string) (Money, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, value)
+
+	cleaned = strings.TrimSuffix(strings.ToLower(cleaned), "сом")
+	cleaned = strings.TrimSuffix(cleaned, "kgs")
+	cleaned = strings.ReplaceAll(cleaned, ",", ".")
+
+	negative := strings.HasPrefix(cleaned, "-")
+	cleaned = strings.TrimPrefix(cleaned, "-")
+
+	whole, fraction, _ := strings.Cut(cleaned, ".")
+	if !isDigits(whole) || len(fraction) > 2 || fraction != "" && !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	som, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", value, err)
+	}
+
+	tyiyn := int64(0)
+	if fraction != "" {
+		fraction += strings.Repeat("0", 2-len(fraction))
+		if tyiyn, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return Money{}, fmt.Errorf("invalid amount %q: %w", value, err)
+		}
+	}
+
+	amount := som*100 + tyiyn
+	if negative {
+		amount = -amount
+	}
+
+	return Tyiyn(amount), nil
+}
+
+// Add returns the sum of the amounts.
+func (m Money) Add(other Money) Money {
+	return Money{Tyiyn: m.Tyiyn + other.Tyiyn, Currency: m.currencyWith(other)}
+}
+
+// Sub returns the difference of the amounts.
+func (m Money) Sub(other Money) Money {
+	return Money{Tyiyn: m.Tyiyn - other.Tyiyn, Currency: m.currencyWith(other)}
+}
+
+// Mul returns the amount multiplied by n.
+func (m Money) Mul(n int64) Money {
+	return Money{Tyiyn: m.Tyiyn * n, Currency: m.Currency}
+}
+
+// Cmp compares the amounts and returns -1, 0 or +1.
+func (m Money) Cmp(other Money) int {
+	m.currencyWith(other)
+
+	switch {
+	case m.Tyiyn < other.Tyiyn:
+		return -1
+	case m.Tyiyn > other.Tyiyn:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m Money) IsZero() bool {
+	return m.Tyiyn == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Tyiyn < 0
+}
+
+// String formats the amount for display, e.g. "1 234,56 KGS" or "950 KGS" for whole soms.
+func (m Money) String() string {
+	amount := m.Tyiyn
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	whole := strconv.FormatInt(amount/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + " " + whole[i:]
+	}
+
+	currency := m.Currency
+	if currency == "" {
+		currency = CurrencyKGS
+	}
+
+	if fraction := amount % 100; fraction != 0 {
+		return fmt.Sprintf("%s%s,%02d %s", sign, whole, fraction, currency)
+	}
+
+	return fmt.Sprintf("%s%s %s", sign, whole, currency)
+}
+
+// Decimal formats the amount in soms with the dot and two decimals and without the currency, e.g. "1234.50", as
+// the machines expect it.
+func (m Money) Decimal() string {
+	amount := m.Tyiyn
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// currencyWith returns the common currency of the amounts. Mixing currencies is a programming error.
+func (m Money) currencyWith(other Money) string {
+	switch {
+	case m.Currency == "" || m.IsZero() && other.Currency != "":
+		return other.Currency
+	case other.Currency == "" || other.IsZero():
+		return m.Currency
+	case m.Currency != other.Currency:
+		panic(fmt.Sprintf("money: mixing currencies %s and %s", m.Currency, other.Currency))
+	default:
+		return m.Currency
+	}
+}
+
+// isDigits reports whether the value is a non-empty run of ASCII digits, strconv accepts the signs as well.
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
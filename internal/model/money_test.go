@@ -0,0 +1,115 @@
+package model
+
+import (
+	"cmp"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Money
+		wantErr bool
+	}{
+		{value: "1 234,56 сом", want: Tyiyn(123456)},
+		{name: "no-break space between the thousands", value: "1\u00a0234,56 сом", want: Tyiyn(123456)},
+		{value: "950", want: Som(950)},
+		{value: "950 KGS", want: Som(950)},
+		{value: "12,5", want: Tyiyn(1250)},
+		{value: "0.05", want: Tyiyn(5)},
+		{value: "-12.5 сом", want: Tyiyn(-1250)},
+		{value: "- 1 000,01 сом", want: Tyiyn(-100001)},
+		{value: "12,345", wantErr: true},
+		{value: "12.-5", wantErr: true},
+		{value: "12.+5", wantErr: true},
+		{value: "12.5.0", wantErr: true},
+		{value: "--12", wantErr: true},
+		{value: "+12", wantErr: true},
+		{value: ",50", wantErr: true},
+		{value: "сом", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "12 USD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(cmp.Or(tt.name, tt.value), func(t *testing.T) {
+			got, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMoney(%q) = %v, want an error", tt.value, got)
+				}
+
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("ParseMoney(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money       Money
+		wantString  string
+		wantDecimal string
+	}{
+		{money: Tyiyn(123456), wantString: "1 234,56 KGS", wantDecimal: "1234.56"},
+		{money: Som(950), wantString: "950 KGS", wantDecimal: "950.00"},
+		{money: Tyiyn(-1250), wantString: "-12,50 KGS", wantDecimal: "-12.50"},
+		{money: Tyiyn(-5), wantString: "-0,05 KGS", wantDecimal: "-0.05"},
+		{money: Som(1000000), wantString: "1 000 000 KGS", wantDecimal: "1000000.00"},
+		{money: Money{}, wantString: "0 KGS", wantDecimal: "0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantString, func(t *testing.T) {
+			if got := tt.money.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+
+			if got := tt.money.Decimal(); got != tt.wantDecimal {
+				t.Errorf("Decimal() = %q, want %q", got, tt.wantDecimal)
+			}
+		})
+	}
+}
+
+func TestMoneyCurrency(t *testing.T) {
+	usd := Money{Tyiyn: 100, Currency: "USD"}
+
+	tests := []struct {
+		name      string
+		op        func() Money
+		want      Money
+		wantPanic bool
+	}{
+		{name: "same currency", op: func() Money { return Som(10).Add(Som(5)) }, want: Som(15)},
+		{name: "zero value takes the currency", op: func() Money { return Money{}.Add(Som(5)) }, want: Som(5)},
+		{name: "zero of another currency", op: func() Money { return usd.Sub(Tyiyn(0)) }, want: usd},
+		{name: "negative result", op: func() Money { return Som(5).Sub(Som(10)) }, want: Som(-5)},
+		{name: "mixing currencies in Add", op: func() Money { return Som(10).Add(usd) }, wantPanic: true},
+		{name: "mixing currencies in Sub", op: func() Money { return usd.Sub(Som(10)) }, wantPanic: true},
+		{
+			name:      "mixing currencies in Cmp",
+			op:        func() Money { return Tyiyn(int64(Som(10).Cmp(usd))) },
+			wantPanic: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recovered := recover(); (recovered != nil) != tt.wantPanic {
+					t.Errorf("panic = %v, want panic %v", recovered, tt.wantPanic)
+				}
+			}()
+
+			if got := tt.op(); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// NotificationKind is what the notification tells the user about, it determines the payload.
+type NotificationKind string
+
+const (
+	NotificationKindPaymentDue    NotificationKind = "payment_due"
+	NotificationKindStatusChanged NotificationKind = "status_changed"
+	NotificationKindPeriodDigest  NotificationKind = "period_digest"
+	NotificationKindChargeAnomaly NotificationKind = "charge_anomaly"
+	NotificationKindTariffChanged NotificationKind = "tariff_changed"
+)
+
+// NotificationStatus is the state of the delivery of the notification.
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "pending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// Notification is the outgoing notification in the outbox. It is written together with the state change it tells
+// about and delivered later, so that it is neither lost when the delivery fails or the process restarts nor sent
+// twice for the same change.
+type Notification struct {
+	ID int `gorm:"primaryKey"`
+	// UserID is the Telegram ID of the recipient.
+	UserID int64 `gorm:"index"`
+	Kind   NotificationKind
+	// IdempotencyKey identifies the change the notification tells about, the second notification with the same key
+	// is not stored.
+	IdempotencyKey string `gorm:"uniqueIndex"`
+	// Payload is the JSON with the data of the kind.
+	Payload       []byte
+	Status        NotificationStatus `gorm:"index"`
+	Attempts      int
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
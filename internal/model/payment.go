@@ -0,0 +1,29 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Payment is a top-up of the account listed in the personal cabinet. The cabinet lists the same payments on every
+// visit, so they are deduplicated by the fingerprint of the account, the date, the amount and the source.
+type Payment struct {
+	ID          int       `gorm:"primaryKey"`
+	AccountID   int       `gorm:"index"`
+	PaidAt      time.Time `gorm:"index"`
+	Amount      Money     `gorm:"embedded;embeddedPrefix:amount_"`
+	Source      string
+	Fingerprint string `gorm:"uniqueIndex"`
+	CreatedAt   time.Time
+}
+
+// NewPayment returns the payment of the account with the fingerprint set.
+func NewPayment(accountID int, paidAt time.Time, amount Money, source string) Payment {
+	return Payment{
+		AccountID:   accountID,
+		PaidAt:      paidAt,
+		Amount:      amount,
+		Source:      source,
+		Fingerprint: fmt.Sprintf("%d|%s|%d %s|%s", accountID, paidAt.UTC().Format(time.RFC3339), amount.Tyiyn, amount.Currency, source),
+	}
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Profile is the subscriber profile of the account in the personal cabinet. It is personal data, so it is fetched
+// on demand and never stored.
+type Profile struct {
+	OwnerName      string
+	Address        string
+	TariffPlan     string
+	ContractNumber string
+	Phone          string
+}
+
+// Masked returns the profile with the personal fields partially hidden: the first letter of every word of the name,
+// the beginning of the address and the last digits of the contract number and the phone are kept.
+func (p Profile) Masked() Profile {
+	return Profile{
+		OwnerName:      maskWords(p.OwnerName, 1),
+		Address:        maskTail(p.Address, 6),
+		TariffPlan:     p.TariffPlan,
+		ContractNumber: maskHead(p.ContractNumber, 3),
+		Phone:          maskHead(p.Phone, 4),
+	}
+}
+
+// maskWords keeps the first runes of every word.
+func maskWords(value string, keep int) string {
+	words := strings.Fields(value)
+	for i, word := range words {
+		words[i] = maskTail(word, keep)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// maskTail keeps the first runes of the value and hides the rest.
+func maskTail(value string, keep int) string {
+	runes := []rune(value)
+	if len(runes) <= keep {
+		return value
+	}
+
+	return string(runes[:keep]) + strings.Repeat("*", len(runes)-keep)
+}
+
+// maskHead keeps the last letters and digits of the value and hides the rest, the separators are kept.
+func maskHead(value string, keep int) string {
+	runes := []rune(value)
+	for i := len(runes) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			continue
+		}
+
+		if keep > 0 {
+			keep--
+			continue
+		}
+
+		runes[i] = '*'
+	}
+
+	return string(runes)
+}
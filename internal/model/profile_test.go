@@ -0,0 +1,45 @@
+package model
+
+import "testing"
+
+func TestProfileMasked(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		want    Profile
+	}{
+		{
+			name: "all the fields",
+			profile: Profile{
+				OwnerName:      "Иванов Иван",
+				Address:        "г. Бишкек, ул. Киевская, 1",
+				TariffPlan:     "Оптимальный 100",
+				ContractNumber: "KG-000123",
+				Phone:          "+996 555 123 456",
+			},
+			want: Profile{
+				OwnerName:      "И***** И***",
+				Address:        "г. Биш********************",
+				TariffPlan:     "Оптимальный 100",
+				ContractNumber: "**-***123",
+				Phone:          "+*** *** **3 456",
+			},
+		},
+		{
+			name:    "short values are kept",
+			profile: Profile{OwnerName: "А Б", Address: "Ош", ContractNumber: "12", Phone: "0555"},
+			want:    Profile{OwnerName: "А Б", Address: "Ош", ContractNumber: "12", Phone: "0555"},
+		},
+		{
+			name: "empty profile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.Masked(); got != tt.want {
+				t.Errorf("Masked() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
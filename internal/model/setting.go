@@ -0,0 +1,10 @@
+package model
+
+// SettingAuditSalt is the salt of the audit log generated on the first start when audit.salt is not configured.
+const SettingAuditSalt = "audit.salt"
+
+// Setting is a value the bot generates once and keeps across the restarts.
+type Setting struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
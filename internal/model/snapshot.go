@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// BalanceSnapshot is the state of the account observed by a single balance refresh. The account keeps only the
+// latest state, the snapshots are the balance history the data export includes.
+type BalanceSnapshot struct {
+	ID           int           `gorm:"primaryKey"`
+	AccountID    int           `gorm:"index"`
+	Balance      Money         `gorm:"embedded;embeddedPrefix:balance_"`
+	TariffAmount Money         `gorm:"embedded;embeddedPrefix:tariff_"`
+	Billing      BillingPeriod `gorm:"embedded;embeddedPrefix:billing_"`
+	CreatedAt    time.Time
+	TariffName   string
+}
@@ -6,5 +6,7 @@ type User struct {
 	AuthUsername string `gorm:"unique"`
 	AuthPassword string
 	Session      string
-	Accounts     []Account `gorm:"foreignKey:UserID"`
+	// CalendarTokenHash is the keyed hash of the token of the calendar feed, nil when the feed is not issued.
+	CalendarTokenHash *string   `gorm:"uniqueIndex"`
+	Accounts          []Account `gorm:"foreignKey:UserID"`
 }
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -16,6 +18,42 @@ func NewAccountStorage(db *gorm.DB) *AccountStorage {
 	return &AccountStorage{db: db}
 }
 
-func (s *AccountStorage) Save(ctx context.Context, user *model.Account) error {
-	return s.db.WithContext(ctx).Save(user).Error
+// Save stores the account as read from the personal cabinet. The settings of the owner are kept as stored, so the
+// refresh running while the owner changes them does not revert the change with its stale copy.
+func (s *AccountStorage) Save(ctx context.Context, account *model.Account) error {
+	return conn(ctx, s.db).Omit("reminded_for", "digest_enabled", "anomaly_sensitivity").Save(account).Error
+}
+
+// DeleteByIDs deletes the accounts with their history and payments in a single transaction.
+func (s *AccountStorage) DeleteByIDs(ctx context.Context, accountIDs []int) error {
+	return conn(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("account_id IN ?", accountIDs).Delete(&model.BalanceSnapshot{}).Error; err != nil {
+			return fmt.Errorf("delete snapshots: %w", err)
+		}
+
+		if err := tx.Where("account_id IN ?", accountIDs).Delete(&model.Payment{}).Error; err != nil {
+			return fmt.Errorf("delete payments: %w", err)
+		}
+
+		if err := tx.Where("id IN ?", accountIDs).Delete(&model.Account{}).Error; err != nil {
+			return fmt.Errorf("delete accounts: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// SetRemindedFor records that the payment reminder was sent for the billing period ending at periodEnd.
+func (s *AccountStorage) SetRemindedFor(ctx context.Context, accountID int, periodEnd time.Time) error {
+	return conn(ctx, s.db).Model(&model.Account{}).Where("id = ?", accountID).Update("reminded_for", periodEnd.UTC()).Error
+}
+
+// SetDigestEnabled turns the digest of the billing period of the account on or off.
+func (s *AccountStorage) SetDigestEnabled(ctx context.Context, accountID int, enabled bool) error {
+	return conn(ctx, s.db).Model(&model.Account{}).Where("id = ?", accountID).Update("digest_enabled", enabled).Error
+}
+
+// SetAnomalySensitivity sets how large the unexpected charge of the account must be to alert the owner.
+func (s *AccountStorage) SetAnomalySensitivity(ctx context.Context, accountID int, sensitivity model.AnomalySensitivity) error {
+	return conn(ctx, s.db).Model(&model.Account{}).Where("id = ?", accountID).Update("anomaly_sensitivity", sensitivity).Error
 }
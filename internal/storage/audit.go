@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// AuditStorage keeps the audit log. It is append-only: the events are never updated and only removed by
+// DeleteOlderThan when they are past the retention period.
+type AuditStorage struct {
+	db *gorm.DB
+}
+
+func NewAuditStorage(db *gorm.DB) *AuditStorage {
+	return &AuditStorage{db: db}
+}
+
+func (s *AuditStorage) Create(ctx context.Context, event *model.AuditEvent) error {
+	return conn(ctx, s.db).Create(event).Error
+}
+
+// List returns the events matching the filter, the newest first.
+func (s *AuditStorage) List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	query := conn(ctx, s.db).Order("created_at DESC, id DESC")
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if filter.ActorHash != "" {
+		query = query.Where("actor_hash = ?", filter.ActorHash)
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []model.AuditEvent
+	err := query.Find(&events).Error
+	return events, err
+}
+
+// DeleteOlderThan removes the events created before the given time and returns the number of removed events.
+func (s *AuditStorage) DeleteOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, s.db).Where("created_at < ?", before.UTC()).Delete(&model.AuditEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type LoginAttemptStorage struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptStorage(db *gorm.DB) *LoginAttemptStorage {
+	return &LoginAttemptStorage{db: db}
+}
+
+// Get returns the attempts of the subject, ErrNotFound is returned if there were no failed attempts.
+func (s *LoginAttemptStorage) Get(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	if err := conn(ctx, s.db).Where("subject = ?", subject).First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &attempt, nil
+}
+
+func (s *LoginAttemptStorage) Save(ctx context.Context, attempt *model.LoginAttempt) error {
+	return conn(ctx, s.db).Save(attempt).Error
+}
+
+// Delete removes the attempts of the subjects and returns the number of the removed records.
+func (s *LoginAttemptStorage) Delete(ctx context.Context, subjects ...string) (int64, error) {
+	result := conn(ctx, s.db).Where("subject IN ?", subjects).Delete(&model.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// NotificationStorage keeps the outbox of the outgoing notifications.
+type NotificationStorage struct {
+	db *gorm.DB
+}
+
+func NewNotificationStorage(db *gorm.DB) *NotificationStorage {
+	return &NotificationStorage{db: db}
+}
+
+// Enqueue stores the notification unless the notification with the same idempotency key is stored already. It
+// reports whether the notification is new.
+func (s *NotificationStorage) Enqueue(ctx context.Context, notification *model.Notification) (bool, error) {
+	result := conn(ctx, s.db).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "idempotency_key"}}, DoNothing: true}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListByUserID returns the notifications of the user in the outbox, the oldest first.
+func (s *NotificationStorage) ListByUserID(ctx context.Context, userID int64) ([]model.Notification, error) {
+	var notifications []model.Notification
+	err := conn(ctx, s.db).Where("user_id = ?", userID).Order("created_at, id").Find(&notifications).Error
+	return notifications, err
+}
+
+// ListDue returns the pending notifications whose next attempt is due at the moment, the oldest first.
+func (s *NotificationStorage) ListDue(ctx context.Context, now time.Time, limit int) ([]model.Notification, error) {
+	var notifications []model.Notification
+	err := conn(ctx, s.db).
+		Where("status = ? AND next_attempt_at <= ?", model.NotificationStatusPending, now.UTC()).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkSent records that the notification was delivered.
+func (s *NotificationStorage) MarkSent(ctx context.Context, id int, attempts int, sentAt time.Time) error {
+	return conn(ctx, s.db).Model(&model.Notification{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     model.NotificationStatusSent,
+		"attempts":   attempts,
+		"sent_at":    sentAt.UTC(),
+		"last_error": "",
+	}).Error
+}
+
+// MarkRetry records the failed attempt and when to try again.
+func (s *NotificationStorage) MarkRetry(ctx context.Context, id int, attempts int, nextAttemptAt time.Time, lastError string) error {
+	return conn(ctx, s.db).Model(&model.Notification{}).Where("id = ?", id).Updates(map[string]any{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt.UTC(),
+		"last_error":      lastError,
+	}).Error
+}
+
+// MarkFailed records the last failed attempt after which the notification is given up.
+func (s *NotificationStorage) MarkFailed(ctx context.Context, id int, attempts int, lastError string) error {
+	return conn(ctx, s.db).Model(&model.Notification{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     model.NotificationStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	}).Error
+}
+
+// DeleteFinishedBefore removes the sent and the given up notifications last updated before the moment, the pending
+// ones are kept. It returns the number of the removed notifications.
+func (s *NotificationStorage) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := conn(ctx, s.db).
+		Where("status IN ? AND updated_at < ?", []model.NotificationStatus{model.NotificationStatusSent, model.NotificationStatusFailed}, before.UTC()).
+		Delete(&model.Notification{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type PaymentStorage struct {
+	db *gorm.DB
+}
+
+func NewPaymentStorage(db *gorm.DB) *PaymentStorage {
+	return &PaymentStorage{db: db}
+}
+
+// CreateIfNew stores the payment unless the payment with the same fingerprint is stored already. It reports whether
+// the payment is new.
+func (s *PaymentStorage) CreateIfNew(ctx context.Context, payment *model.Payment) (bool, error) {
+	result := conn(ctx, s.db).Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "fingerprint"}}, DoNothing: true}).Create(payment)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListRecent returns the newest payments of the account.
+func (s *PaymentStorage) ListRecent(ctx context.Context, accountID int, limit int) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := conn(ctx, s.db).Where("account_id = ?", accountID).Order("paid_at DESC, id DESC").Limit(limit).Find(&payments).Error
+	return payments, err
+}
+
+// ListByAccountIDs returns the payments of the accounts ordered from the oldest to the newest.
+func (s *PaymentStorage) ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.Payment, error) {
+	var payments []model.Payment
+	if len(accountIDs) == 0 {
+		return payments, nil
+	}
+
+	err := conn(ctx, s.db).Where("account_id IN ?", accountIDs).Order("paid_at, id").Find(&payments).Error
+	return payments, err
+}
+
+// ListByAccountSince returns the payments of the account made since the moment ordered from the oldest to the
+// newest, the zero moment returns all of them.
+func (s *PaymentStorage) ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := conn(ctx, s.db).Where("account_id = ? AND paid_at >= ?", accountID, since.UTC()).Order("paid_at, id").Find(&payments).Error
+	return payments, err
+}
+
+// ListByAccountBetween returns the payments of the account made after the start and up to the end ordered from the
+// oldest to the newest.
+func (s *PaymentStorage) ListByAccountBetween(ctx context.Context, accountID int, after, until time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := conn(ctx, s.db).Where("account_id = ? AND paid_at > ? AND paid_at <= ?", accountID, after.UTC(), until.UTC()).Order("paid_at, id").Find(&payments).Error
+	return payments, err
+}
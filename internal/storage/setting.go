@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type SettingStorage struct {
+	db *gorm.DB
+}
+
+func NewSettingStorage(db *gorm.DB) *SettingStorage {
+	return &SettingStorage{db: db}
+}
+
+// GetOrCreate returns the value of the setting, the value is stored first if the setting doesn't exist. The stored
+// value wins when several processes create the setting at once.
+func (s *SettingStorage) GetOrCreate(ctx context.Context, key, value string) (string, error) {
+	setting := model.Setting{Key: key, Value: value}
+	if err := conn(ctx, s.db).Clauses(clause.OnConflict{DoNothing: true}).Create(&setting).Error; err != nil {
+		return "", err
+	}
+
+	// The primary key of the setting selects the stored row
+	if err := conn(ctx, s.db).First(&setting).Error; err != nil {
+		return "", err
+	}
+
+	return setting.Value, nil
+}
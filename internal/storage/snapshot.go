@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type SnapshotStorage struct {
+	db *gorm.DB
+}
+
+func NewSnapshotStorage(db *gorm.DB) *SnapshotStorage {
+	return &SnapshotStorage{db: db}
+}
+
+func (s *SnapshotStorage) Create(ctx context.Context, snapshot *model.BalanceSnapshot) error {
+	return conn(ctx, s.db).Create(snapshot).Error
+}
+
+// Latest returns the newest snapshot of the account, ErrNotFound is returned if the account has none.
+func (s *SnapshotStorage) Latest(ctx context.Context, accountID int) (*model.BalanceSnapshot, error) {
+	var snapshot model.BalanceSnapshot
+	if err := conn(ctx, s.db).Where("account_id = ?", accountID).Order("created_at DESC, id DESC").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// ListByAccountIDs returns the snapshots of the accounts ordered from the oldest to the newest.
+func (s *SnapshotStorage) ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.BalanceSnapshot, error) {
+	var snapshots []model.BalanceSnapshot
+	if len(accountIDs) == 0 {
+		return snapshots, nil
+	}
+
+	err := conn(ctx, s.db).Where("account_id IN ?", accountIDs).Order("created_at, id").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// ListByAccountSince returns the snapshots of the account taken since the moment ordered from the oldest to the
+// newest, the zero moment returns all of them.
+func (s *SnapshotStorage) ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.BalanceSnapshot, error) {
+	var snapshots []model.BalanceSnapshot
+	err := conn(ctx, s.db).Where("account_id = ? AND created_at >= ?", accountID, since.UTC()).Order("created_at, id").Find(&snapshots).Error
+	return snapshots, err
+}
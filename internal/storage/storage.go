@@ -1,37 +1,148 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	slogGorm "github.com/orandin/slog-gorm"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormLogger "gorm.io/gorm/logger"
 
 	"github.com/aastashov/megalinekg_bot/internal/model"
 )
 
+// ErrNotFound is returned when the requested record doesn't exist.
+var ErrNotFound = errors.New("not found")
+
 type Storage struct {
 	DB *gorm.DB
 }
 
+type txKey struct{}
+
+// InTransaction runs fn in a database transaction. The storages called with the context passed to fn take part
+// in the transaction, the transaction is rolled back if fn returns an error.
+func (s *Storage) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return conn(ctx, s.DB).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction started by InTransaction or the db itself if the context is not in a transaction.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+
+	return db.WithContext(ctx)
+}
+
 func MustNewPostgresDB(logger *slog.Logger, connectionString string) *Storage {
-	gormLogger := slogGorm.New(
-		slogGorm.WithHandler(logger.Handler()),
-		slogGorm.WithTraceAll(),
-		slogGorm.SetLogLevel(slogGorm.ErrorLogType, slog.LevelError),
-		slogGorm.SetLogLevel(slogGorm.SlowQueryLogType, slog.LevelWarn),
-		slogGorm.SetLogLevel(slogGorm.DefaultLogType, slog.LevelInfo),
-	)
+	db, err := gorm.Open(postgres.Open(connectionString), newGormConfig(logger))
+	if err != nil {
+		panic(fmt.Errorf("open connection: %w", err))
+	}
 
-	db, err := gorm.Open(postgres.Open(connectionString), &gorm.Config{Logger: gormLogger})
+	if err = registerUTCTimes(db); err != nil {
+		panic(fmt.Errorf("register callbacks: %w", err))
+	}
+
+	return &Storage{DB: db}
+}
+
+// MustNewSQLiteDB opens the SQLite database file, it is created if it doesn't exist. The foreign keys are enforced
+// and the writers wait for each other instead of failing with "database is locked", same as they would on Postgres.
+func MustNewSQLiteDB(logger *slog.Logger, path string) *Storage {
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	db, err := gorm.Open(sqlite.Open(dsn), newGormConfig(logger))
 	if err != nil {
 		panic(fmt.Errorf("open connection: %w", err))
 	}
 
+	if err = registerUTCTimes(db); err != nil {
+		panic(fmt.Errorf("register callbacks: %w", err))
+	}
+
 	return &Storage{DB: db}
 }
 
+// newGormConfig returns the config of both drivers, the timestamps gorm sets are in UTC like the rest of the times.
+func newGormConfig(logger *slog.Logger) *gorm.Config {
+	return &gorm.Config{
+		Logger:  newGormLogger(logger),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// registerUTCTimes makes gorm store every time of the created and updated models in UTC. SQLite keeps the times as
+// text with the offset and compares them as text, so the times in different locations, e.g. the payments read in
+// the location of the personal cabinet and the snapshots taken in UTC, would not compare by the moment. The
+// storages pass the times of the queries in UTC for the same reason.
+func registerUTCTimes(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("megaline:utc_times", utcTimes); err != nil {
+		return fmt.Errorf("register create callback: %w", err)
+	}
+
+	if err := db.Callback().Update().Before("gorm:update").Register("megaline:utc_times", utcTimes); err != nil {
+		return fmt.Errorf("register update callback: %w", err)
+	}
+
+	return nil
+}
+
+func utcTimes(db *gorm.DB) {
+	if db.Statement.Schema == nil {
+		return
+	}
+
+	value := reflect.Indirect(db.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			utcFields(db, reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		utcFields(db, value)
+	default:
+	}
+}
+
+func utcFields(db *gorm.DB, model reflect.Value) {
+	ctx := db.Statement.Context
+	for _, field := range db.Statement.Schema.Fields {
+		value, isZero := field.ValueOf(ctx, model)
+		if isZero {
+			continue
+		}
+
+		switch moment := value.(type) {
+		case time.Time:
+			db.AddError(field.Set(ctx, model, moment.UTC()))
+		case *time.Time:
+			utc := moment.UTC()
+			db.AddError(field.Set(ctx, model, &utc))
+		}
+	}
+}
+
+func newGormLogger(logger *slog.Logger) gormLogger.Interface {
+	return slogGorm.New(
+		slogGorm.WithHandler(logger.Handler()),
+		slogGorm.WithTraceAll(),
+		slogGorm.SetLogLevel(slogGorm.ErrorLogType, slog.LevelError),
+		slogGorm.SetLogLevel(slogGorm.SlowQueryLogType, slog.LevelWarn),
+		slogGorm.SetLogLevel(slogGorm.DefaultLogType, slog.LevelInfo),
+	)
+}
+
 func (s *Storage) MustClose() {
 	connection, err := s.DB.DB()
 	if err != nil {
@@ -47,9 +158,58 @@ func (s *Storage) MustMigration() {
 	err := s.DB.AutoMigrate(
 		model.User{},
 		model.Account{},
+		model.BalanceSnapshot{},
+		model.AuditEvent{},
+		model.LoginAttempt{},
+		model.Payment{},
+		model.Notification{},
+		model.Setting{},
 	)
 
 	if err != nil {
 		panic(fmt.Errorf("migrate models: %w", err))
 	}
+
+	for _, table := range []string{"accounts", "balance_snapshots"} {
+		if err = s.migrateMoney(table); err != nil {
+			panic(fmt.Errorf("migrate money of %s: %w", table, err))
+		}
+	}
+}
+
+// migrateMoney moves the legacy float "balance" and int "tariff_amount" columns in soms to model.Money in tyiyn.
+// It does nothing once the legacy columns are dropped.
+func (s *Storage) migrateMoney(table string) error {
+	if !s.DB.Migrator().HasColumn(table, "balance") {
+		return nil
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(table).Where("1 = 1").Updates(map[string]any{
+			"balance_tyiyn":    gorm.Expr("CAST(ROUND(balance * 100) AS BIGINT)"),
+			"balance_currency": model.CurrencyKGS,
+			"tariff_tyiyn":     gorm.Expr("CAST(tariff_amount AS BIGINT) * 100"),
+			"tariff_currency":  model.CurrencyKGS,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("convert amounts: %w", err)
+		}
+
+		for _, column := range []string{"balance", "tariff_amount"} {
+			if err = tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error; err != nil {
+				return fmt.Errorf("drop column %s: %w", column, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	connection, err := s.DB.DB()
+	if err != nil {
+		return fmt.Errorf("get db connection: %w", err)
+	}
+
+	return connection.PingContext(ctx)
 }
@@ -0,0 +1,837 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// postgresURLEnv is the DSN of the Postgres database the tests run against besides SQLite, the Postgres tests are
+// skipped when it is not set. The tables of the database are dropped by the tests.
+const postgresURLEnv = "MEGALINE_TEST_POSTGRES_URL"
+
+var testTables = []string{"users", "accounts", "balance_snapshots", "audit_events", "login_attempts", "payments", "notifications", "settings"}
+
+// runOnDrivers runs the test against a freshly migrated SQLite database and, when postgresURLEnv is set, against
+// the freshly migrated Postgres database.
+func runOnDrivers(t *testing.T, test func(t *testing.T, s *Storage)) {
+	t.Helper()
+
+	runOnEmptyDrivers(t, func(t *testing.T, s *Storage) {
+		s.MustMigration()
+		test(t, s)
+	})
+}
+
+// runOnEmptyDrivers runs the test against the databases without any tables.
+func runOnEmptyDrivers(t *testing.T, test func(t *testing.T, s *Storage)) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("sqlite", func(t *testing.T) {
+		s := MustNewSQLiteDB(logger, filepath.Join(t.TempDir(), "megaline.db"))
+		t.Cleanup(s.MustClose)
+
+		test(t, s)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv(postgresURLEnv)
+		if dsn == "" {
+			t.Skipf("%s is not set", postgresURLEnv)
+		}
+
+		s := MustNewPostgresDB(logger, dsn)
+		t.Cleanup(s.MustClose)
+
+		for _, table := range testTables {
+			if err := s.DB.Migrator().DropTable(table); err != nil {
+				t.Fatalf("drop %s: %v", table, err)
+			}
+		}
+
+		test(t, s)
+	})
+}
+
+func mustCreate(t *testing.T, s *Storage, value any) {
+	t.Helper()
+
+	if err := s.DB.Create(value).Error; err != nil {
+		t.Fatalf("create %T: %v", value, err)
+	}
+}
+
+func count(t *testing.T, s *Storage, model any, query string, args ...any) int64 {
+	t.Helper()
+
+	var n int64
+	if err := s.DB.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatalf("count %T: %v", model, err)
+	}
+
+	return n
+}
+
+func TestInTransaction(t *testing.T) {
+	errRollback := errors.New("rollback")
+
+	tests := []struct {
+		name      string
+		fnErr     error
+		wantUsers int64
+	}{
+		{name: "commit", wantUsers: 1},
+		{name: "rollback", fnErr: errRollback, wantUsers: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runOnDrivers(t, func(t *testing.T, s *Storage) {
+				ctx := context.Background()
+				users := NewUserStorage(s.DB)
+
+				err := s.InTransaction(ctx, func(ctx context.Context) error {
+					if err := users.Save(ctx, &model.User{TelegramID: 1, AuthUsername: "0555000001"}); err != nil {
+						return err
+					}
+
+					// The storage sees the uncommitted user only through the context of the transaction
+					if _, err := users.GetByTelegramID(ctx, 1); err != nil {
+						t.Errorf("GetByTelegramID() in transaction error = %v, want nil", err)
+					}
+
+					return tt.fnErr
+				})
+				if !errors.Is(err, tt.fnErr) {
+					t.Fatalf("InTransaction() error = %v, want %v", err, tt.fnErr)
+				}
+
+				if got := count(t, s, &model.User{}, "telegram_id = ?", 1); got != tt.wantUsers {
+					t.Errorf("users after InTransaction() = %d, want %d", got, tt.wantUsers)
+				}
+			})
+		})
+	}
+}
+
+func TestConn(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		if got := conn(ctx, s.DB).Statement.ConnPool; got != s.DB.Statement.ConnPool {
+			t.Errorf("conn() outside of transaction uses %T, want the db", got)
+		}
+
+		_ = s.InTransaction(ctx, func(ctx context.Context) error {
+			if got := conn(ctx, s.DB).Statement.ConnPool; got == s.DB.Statement.ConnPool {
+				t.Errorf("conn() in transaction uses the db, want the transaction")
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestUserStorageDeleteByTelegramID(t *testing.T) {
+	tests := []struct {
+		name       string
+		telegramID int64
+		wantErr    error
+		wantOwn    int64
+	}{
+		{name: "deletes the user with the data", telegramID: 1, wantOwn: 0},
+		{name: "unknown user", telegramID: 3, wantErr: ErrNotFound, wantOwn: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runOnDrivers(t, func(t *testing.T, s *Storage) {
+				ctx := context.Background()
+
+				// The user 2 is the neighbour whose data must survive
+				accountIDs := make(map[int64]int)
+				for _, telegramID := range []int64{1, 2} {
+					user := model.User{TelegramID: telegramID, AuthUsername: fmt.Sprintf("055500000%d", telegramID)}
+					mustCreate(t, s, &user)
+
+					account := model.Account{UserID: user.ID, Number: fmt.Sprintf("99655500000%d", telegramID)}
+					mustCreate(t, s, &account)
+					accountIDs[telegramID] = account.ID
+
+					mustCreate(t, s, &model.BalanceSnapshot{AccountID: account.ID, Balance: model.Som(100)})
+
+					payment := model.NewPayment(account.ID, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), model.Som(500), "terminal")
+					mustCreate(t, s, &payment)
+
+					notification := model.Notification{
+						UserID:         telegramID,
+						Kind:           model.NotificationKindPaymentDue,
+						IdempotencyKey: "payment_due:" + account.Number,
+						Status:         model.NotificationStatusPending,
+					}
+					mustCreate(t, s, &notification)
+				}
+
+				err := NewUserStorage(s.DB).DeleteByTelegramID(ctx, tt.telegramID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteByTelegramID() error = %v, want %v", err, tt.wantErr)
+				}
+
+				own := accountIDs[1]
+				checks := []struct {
+					model any
+					query string
+					arg   any
+				}{
+					{&model.User{}, "telegram_id = ?", 1},
+					{&model.Account{}, "id = ?", own},
+					{&model.BalanceSnapshot{}, "account_id = ?", own},
+					{&model.Payment{}, "account_id = ?", own},
+					{&model.Notification{}, "user_id = ?", 1},
+				}
+
+				for _, check := range checks {
+					if got := count(t, s, check.model, check.query, check.arg); got != tt.wantOwn {
+						t.Errorf("%T of the user = %d, want %d", check.model, got, tt.wantOwn)
+					}
+				}
+
+				other := accountIDs[2]
+				if got := count(t, s, &model.Payment{}, "account_id = ?", other); got != 1 {
+					t.Errorf("payments of the other user = %d, want 1", got)
+				}
+
+				if got := count(t, s, &model.Notification{}, "user_id = ?", 2); got != 1 {
+					t.Errorf("notifications of the other user = %d, want 1", got)
+				}
+			})
+		})
+	}
+}
+
+func TestUserStorageGetOrCreateByTelegramID(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		users := NewUserStorage(s.DB)
+
+		stored := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &stored)
+		mustCreate(t, s, &model.Account{UserID: stored.ID, Number: "996555000001"})
+
+		user, created, err := users.GetOrCreateByTelegramID(ctx, 1)
+		if err != nil || created {
+			t.Fatalf("GetOrCreateByTelegramID() of the stored user = %v, %v, want false, nil", created, err)
+		}
+
+		if user.ID != stored.ID || len(user.Accounts) != 1 || user.Accounts[0].Number != "996555000001" {
+			t.Errorf("GetOrCreateByTelegramID() of the stored user = %+v, want the user with the account", user)
+		}
+
+		user, created, err = users.GetOrCreateByTelegramID(ctx, 2)
+		if err != nil || !created {
+			t.Fatalf("GetOrCreateByTelegramID() of the new user = %v, %v, want true, nil", created, err)
+		}
+
+		if user.ID == 0 || user.TelegramID != 2 {
+			t.Errorf("GetOrCreateByTelegramID() of the new user = %+v, want the created user", user)
+		}
+
+		if got := count(t, s, &model.User{}, "telegram_id = ?", 2); got != 1 {
+			t.Errorf("users with the new telegram id = %d, want 1", got)
+		}
+	})
+}
+
+func TestUserStorageSave(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		users := NewUserStorage(s.DB)
+
+		user := model.User{TelegramID: 1, AuthUsername: "0555000001", AuthPassword: "old", Session: "session"}
+		if err := users.Save(ctx, &user); err != nil {
+			t.Fatalf("Save() of the new user error = %v", err)
+		}
+
+		// The zero values are written as well, the cleared session must not survive
+		user.AuthUsername, user.AuthPassword, user.Session = "0555000002", "new", ""
+		if err := users.Save(ctx, &user); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		got, err := users.GetByTelegramID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetByTelegramID() error = %v", err)
+		}
+
+		if got.ID != user.ID || got.AuthUsername != "0555000002" || got.AuthPassword != "new" || got.Session != "" {
+			t.Errorf("GetByTelegramID() = %+v, want the saved user %+v", got, user)
+		}
+
+		if n := count(t, s, &model.User{}, "1 = 1"); n != 1 {
+			t.Errorf("users = %d, want 1", n)
+		}
+	})
+}
+
+func TestUserStorageListWithAccounts(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+
+		first := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &first)
+		second := model.User{TelegramID: 2, AuthUsername: "0555000002"}
+		mustCreate(t, s, &second)
+
+		for _, number := range []string{"996555000011", "996555000012"} {
+			mustCreate(t, s, &model.Account{UserID: first.ID, Number: number})
+		}
+
+		got, err := NewUserStorage(s.DB).ListWithAccounts(ctx)
+		if err != nil {
+			t.Fatalf("ListWithAccounts() error = %v", err)
+		}
+
+		if len(got) != 2 || got[0].ID != first.ID || got[1].ID != second.ID {
+			t.Fatalf("ListWithAccounts() = %+v, want both users ordered by id", got)
+		}
+
+		if len(got[0].Accounts) != 2 || len(got[1].Accounts) != 0 {
+			t.Errorf("accounts = %d, %d, want 2, 0", len(got[0].Accounts), len(got[1].Accounts))
+		}
+	})
+}
+
+func TestAccountStorageSave(t *testing.T) {
+	bishkek := time.FixedZone("Asia/Bishkek", 6*60*60)
+
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		accounts := NewAccountStorage(s.DB)
+
+		user := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &user)
+
+		account := model.Account{
+			UserID:       user.ID,
+			Number:       "996555000001",
+			Billing:      model.BillingPeriod{From: time.Date(2026, 10, 1, 0, 0, 0, 0, bishkek), To: time.Date(2026, 10, 31, 0, 0, 0, 0, bishkek)},
+			TariffAmount: model.Som(950),
+			Balance:      model.Tyiyn(12345),
+			Status:       model.AccountStatusActive,
+			TariffName:   "Оптимальный",
+			Info:         model.AccountInfo{{Key: "Тариф", Value: "Оптимальный"}},
+		}
+		if err := accounts.Save(ctx, &account); err != nil {
+			t.Fatalf("Save() of the new account error = %v", err)
+		}
+
+		account.Balance = model.Tyiyn(-1250)
+		account.Status = model.AccountStatusBlocked
+		if err := accounts.Save(ctx, &account); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		var got model.Account
+		if err := s.DB.First(&got, account.ID).Error; err != nil {
+			t.Fatalf("load account: %v", err)
+		}
+
+		if !got.Billing.Equal(account.Billing) {
+			t.Errorf("billing = %+v, want %+v", got.Billing, account.Billing)
+		}
+
+		if got.Balance != model.Tyiyn(-1250) || got.TariffAmount != model.Som(950) || got.Status != model.AccountStatusBlocked {
+			t.Errorf("balance, tariff, status = %+v, %+v, %q, want the saved values", got.Balance, got.TariffAmount, got.Status)
+		}
+
+		if value, ok := got.Info.Get("Тариф"); got.TariffName != "Оптимальный" || !ok || value != "Оптимальный" {
+			t.Errorf("tariff name, info = %q, %+v, want the saved values", got.TariffName, got.Info)
+		}
+
+		if n := count(t, s, &model.Account{}, "number = ?", account.Number); n != 1 {
+			t.Errorf("accounts = %d, want 1", n)
+		}
+	})
+}
+
+func TestAccountStorageDeleteByIDs(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		user := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &user)
+
+		var ids []int
+		for _, number := range []string{"996555000001", "996555000002"} {
+			account := model.Account{UserID: user.ID, Number: number}
+			mustCreate(t, s, &account)
+			ids = append(ids, account.ID)
+
+			mustCreate(t, s, &model.BalanceSnapshot{AccountID: account.ID, Balance: model.Som(100)})
+
+			payment := model.NewPayment(account.ID, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), model.Som(500), "terminal")
+			mustCreate(t, s, &payment)
+		}
+
+		if err := NewAccountStorage(s.DB).DeleteByIDs(context.Background(), ids[:1]); err != nil {
+			t.Fatalf("DeleteByIDs() error = %v", err)
+		}
+
+		for i, want := range []int64{0, 1} {
+			if got := count(t, s, &model.Account{}, "id = ?", ids[i]); got != want {
+				t.Errorf("account %d = %d, want %d", ids[i], got, want)
+			}
+
+			if got := count(t, s, &model.BalanceSnapshot{}, "account_id = ?", ids[i]); got != want {
+				t.Errorf("snapshots of the account %d = %d, want %d", ids[i], got, want)
+			}
+
+			if got := count(t, s, &model.Payment{}, "account_id = ?", ids[i]); got != want {
+				t.Errorf("payments of the account %d = %d, want %d", ids[i], got, want)
+			}
+		}
+	})
+}
+
+func TestAccountStorageSaveKeepsSettings(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		accounts := NewAccountStorage(s.DB)
+
+		user := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &user)
+
+		// The refresh reads the account before the owner changes the settings and saves it afterwards
+		stale := model.Account{UserID: user.ID, Number: "996555000001", Balance: model.Som(100)}
+		mustCreate(t, s, &stale)
+
+		periodEnd := time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)
+		if err := accounts.SetRemindedFor(ctx, stale.ID, periodEnd); err != nil {
+			t.Fatalf("SetRemindedFor() error = %v", err)
+		}
+
+		if err := accounts.SetDigestEnabled(ctx, stale.ID, true); err != nil {
+			t.Fatalf("SetDigestEnabled() error = %v", err)
+		}
+
+		if err := accounts.SetAnomalySensitivity(ctx, stale.ID, model.AnomalySensitivityOff); err != nil {
+			t.Fatalf("SetAnomalySensitivity() error = %v", err)
+		}
+
+		stale.Balance = model.Som(50)
+		if err := accounts.Save(ctx, &stale); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		var got model.Account
+		if err := s.DB.First(&got, stale.ID).Error; err != nil {
+			t.Fatalf("load account: %v", err)
+		}
+
+		if got.Balance != model.Som(50) {
+			t.Errorf("balance = %+v, want the saved balance", got.Balance)
+		}
+
+		if !got.RemindedFor.Equal(periodEnd) || !got.DigestEnabled || got.AnomalySensitivity != model.AnomalySensitivityOff {
+			t.Errorf("reminded for, digest, sensitivity = %s, %v, %q, want the settings kept", got.RemindedFor, got.DigestEnabled, got.AnomalySensitivity)
+		}
+	})
+}
+
+func TestAccountStorageSettings(t *testing.T) {
+	bishkek := time.FixedZone("Asia/Bishkek", 6*60*60)
+
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		accounts := NewAccountStorage(s.DB)
+
+		user := model.User{TelegramID: 1, AuthUsername: "0555000001"}
+		mustCreate(t, s, &user)
+
+		account := model.Account{UserID: user.ID, Number: "996555000001"}
+		mustCreate(t, s, &account)
+		other := model.Account{UserID: user.ID, Number: "996555000002"}
+		mustCreate(t, s, &other)
+
+		periodEnd := time.Date(2026, 10, 31, 0, 0, 0, 0, bishkek)
+		if err := accounts.SetRemindedFor(ctx, account.ID, periodEnd); err != nil {
+			t.Fatalf("SetRemindedFor() error = %v", err)
+		}
+
+		if err := accounts.SetDigestEnabled(ctx, account.ID, true); err != nil {
+			t.Fatalf("SetDigestEnabled() error = %v", err)
+		}
+
+		if err := accounts.SetAnomalySensitivity(ctx, account.ID, model.AnomalySensitivityHigh); err != nil {
+			t.Fatalf("SetAnomalySensitivity() error = %v", err)
+		}
+
+		var got model.Account
+		if err := s.DB.First(&got, account.ID).Error; err != nil {
+			t.Fatalf("load account: %v", err)
+		}
+
+		if !got.RemindedFor.Equal(periodEnd) || !got.DigestEnabled || got.AnomalySensitivity != model.AnomalySensitivityHigh {
+			t.Errorf("reminded for, digest, sensitivity = %s, %v, %q, want %s, true, %q", got.RemindedFor, got.DigestEnabled, got.AnomalySensitivity, periodEnd, model.AnomalySensitivityHigh)
+		}
+
+		// The false value is written, not skipped as the zero value
+		if err := accounts.SetDigestEnabled(ctx, account.ID, false); err != nil {
+			t.Fatalf("SetDigestEnabled(false) error = %v", err)
+		}
+
+		if n := count(t, s, &model.Account{}, "id = ? AND digest_enabled = ?", account.ID, true); n != 0 {
+			t.Errorf("digest enabled after turning it off")
+		}
+
+		var untouched model.Account
+		if err := s.DB.First(&untouched, other.ID).Error; err != nil {
+			t.Fatalf("load the other account: %v", err)
+		}
+
+		if !untouched.RemindedFor.IsZero() || untouched.DigestEnabled || untouched.AnomalySensitivity != "" {
+			t.Errorf("the other account = %+v, want its settings untouched", untouched)
+		}
+	})
+}
+
+func TestPaymentStorageCreateIfNew(t *testing.T) {
+	paidAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		second   model.Payment
+		wantNew  bool
+		wantRows int64
+	}{
+		{
+			name:     "same payment listed again",
+			second:   model.NewPayment(1, paidAt, model.Som(500), "terminal"),
+			wantNew:  false,
+			wantRows: 1,
+		},
+		{
+			name:     "different amount",
+			second:   model.NewPayment(1, paidAt, model.Som(600), "terminal"),
+			wantNew:  true,
+			wantRows: 2,
+		},
+		{
+			name:     "different source",
+			second:   model.NewPayment(1, paidAt, model.Som(500), "bank"),
+			wantNew:  true,
+			wantRows: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runOnDrivers(t, func(t *testing.T, s *Storage) {
+				ctx := context.Background()
+				payments := NewPaymentStorage(s.DB)
+
+				first := model.NewPayment(1, paidAt, model.Som(500), "terminal")
+				if created, err := payments.CreateIfNew(ctx, &first); err != nil || !created {
+					t.Fatalf("CreateIfNew() of the first payment = %v, %v, want true, nil", created, err)
+				}
+
+				second := tt.second
+				created, err := payments.CreateIfNew(ctx, &second)
+				if err != nil {
+					t.Fatalf("CreateIfNew() error = %v", err)
+				}
+
+				if created != tt.wantNew {
+					t.Errorf("CreateIfNew() = %v, want %v", created, tt.wantNew)
+				}
+
+				if got := count(t, s, &model.Payment{}, "account_id = ?", 1); got != tt.wantRows {
+					t.Errorf("payments = %d, want %d", got, tt.wantRows)
+				}
+			})
+		})
+	}
+}
+
+func TestPaymentStorageListByAccountBetween(t *testing.T) {
+	bishkek := time.FixedZone("Asia/Bishkek", 6*60*60)
+
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		payments := NewPaymentStorage(s.DB)
+
+		// The payments are read in the location of the personal cabinet, the snapshots are taken in UTC.
+		for _, paidAt := range []time.Time{
+			time.Date(2026, 10, 1, 12, 30, 0, 0, bishkek),
+			time.Date(2026, 10, 1, 14, 30, 0, 0, bishkek),
+			time.Date(2026, 10, 1, 15, 0, 0, 0, bishkek),
+		} {
+			payment := model.NewPayment(1, paidAt, model.Som(500), "terminal")
+			if _, err := payments.CreateIfNew(ctx, &payment); err != nil {
+				t.Fatalf("CreateIfNew() error = %v", err)
+			}
+		}
+
+		after := time.Date(2026, 10, 1, 7, 0, 0, 0, time.UTC)
+		until := time.Date(2026, 10, 1, 8, 45, 0, 0, time.UTC)
+
+		got, err := payments.ListByAccountBetween(ctx, 1, after, until)
+		if err != nil {
+			t.Fatalf("ListByAccountBetween() error = %v", err)
+		}
+
+		want := time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)
+		if len(got) != 1 || !got[0].PaidAt.Equal(want) {
+			t.Fatalf("ListByAccountBetween() = %+v, want the payment made at %s", got, want)
+		}
+
+		since, err := payments.ListByAccountSince(ctx, 1, until.In(bishkek))
+		if err != nil {
+			t.Fatalf("ListByAccountSince() error = %v", err)
+		}
+
+		if len(since) != 1 || !since[0].PaidAt.Equal(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("ListByAccountSince() = %+v, want the last payment only", since)
+		}
+	})
+}
+
+func TestSnapshotStorageListByAccountSince(t *testing.T) {
+	bishkek := time.FixedZone("Asia/Bishkek", 6*60*60)
+
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		snapshots := NewSnapshotStorage(s.DB)
+
+		for _, createdAt := range []time.Time{
+			time.Date(2026, 10, 1, 7, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		} {
+			if err := snapshots.Create(ctx, &model.BalanceSnapshot{AccountID: 1, CreatedAt: createdAt}); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+
+		// 14:30 in Bishkek is 08:30 UTC, between the snapshots
+		got, err := snapshots.ListByAccountSince(ctx, 1, time.Date(2026, 10, 1, 14, 30, 0, 0, bishkek))
+		if err != nil {
+			t.Fatalf("ListByAccountSince() error = %v", err)
+		}
+
+		if len(got) != 1 || !got[0].CreatedAt.Equal(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)) {
+			t.Errorf("ListByAccountSince() = %+v, want the snapshot taken at 09:00 UTC", got)
+		}
+	})
+}
+
+func TestNotificationStorageEnqueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		wantNew  bool
+		wantRows int64
+	}{
+		{name: "same key", key: "status_changed:1", wantNew: false, wantRows: 1},
+		{name: "other key", key: "status_changed:2", wantNew: true, wantRows: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runOnDrivers(t, func(t *testing.T, s *Storage) {
+				ctx := context.Background()
+				notifications := NewNotificationStorage(s.DB)
+
+				first := model.Notification{UserID: 1, Kind: model.NotificationKindStatusChanged, IdempotencyKey: "status_changed:1", Payload: []byte(`{"n":1}`), Status: model.NotificationStatusPending}
+				if created, err := notifications.Enqueue(ctx, &first); err != nil || !created {
+					t.Fatalf("Enqueue() of the first notification = %v, %v, want true, nil", created, err)
+				}
+
+				second := model.Notification{UserID: 1, Kind: model.NotificationKindStatusChanged, IdempotencyKey: tt.key, Payload: []byte(`{"n":2}`), Status: model.NotificationStatusPending}
+				created, err := notifications.Enqueue(ctx, &second)
+				if err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+
+				if created != tt.wantNew {
+					t.Errorf("Enqueue() = %v, want %v", created, tt.wantNew)
+				}
+
+				if got := count(t, s, &model.Notification{}, "user_id = ?", 1); got != tt.wantRows {
+					t.Errorf("notifications = %d, want %d", got, tt.wantRows)
+				}
+
+				// The duplicate does not overwrite the stored payload
+				var stored model.Notification
+				if err = s.DB.Where("idempotency_key = ?", "status_changed:1").First(&stored).Error; err != nil {
+					t.Fatalf("load the first notification: %v", err)
+				}
+
+				if string(stored.Payload) != `{"n":1}` {
+					t.Errorf("payload = %s, want the payload of the first notification", stored.Payload)
+				}
+			})
+		})
+	}
+}
+
+func TestNotificationStorageDeleteFinishedBefore(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		notifications := NewNotificationStorage(s.DB)
+
+		now := time.Now()
+		old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+
+		rows := []struct {
+			key       string
+			status    model.NotificationStatus
+			updatedAt time.Time
+			wantKept  bool
+		}{
+			{key: "sent:old", status: model.NotificationStatusSent, updatedAt: old},
+			{key: "failed:old", status: model.NotificationStatusFailed, updatedAt: old},
+			{key: "pending:old", status: model.NotificationStatusPending, updatedAt: old, wantKept: true},
+			{key: "sent:recent", status: model.NotificationStatusSent, updatedAt: recent, wantKept: true},
+			{key: "failed:recent", status: model.NotificationStatusFailed, updatedAt: recent, wantKept: true},
+		}
+
+		for _, row := range rows {
+			notification := model.Notification{UserID: 1, Kind: model.NotificationKindStatusChanged, IdempotencyKey: row.key, Status: row.status, CreatedAt: row.updatedAt, UpdatedAt: row.updatedAt}
+			if _, err := notifications.Enqueue(ctx, &notification); err != nil {
+				t.Fatalf("Enqueue(%s) error = %v", row.key, err)
+			}
+		}
+
+		deleted, err := notifications.DeleteFinishedBefore(ctx, now.Add(-24*time.Hour))
+		if err != nil || deleted != 2 {
+			t.Fatalf("DeleteFinishedBefore() = %d, %v, want 2, nil", deleted, err)
+		}
+
+		for _, row := range rows {
+			kept := count(t, s, &model.Notification{}, "idempotency_key = ?", row.key) == 1
+			if kept != row.wantKept {
+				t.Errorf("notification %s kept = %v, want %v", row.key, kept, row.wantKept)
+			}
+		}
+	})
+}
+
+func TestSettingStorageGetOrCreate(t *testing.T) {
+	runOnDrivers(t, func(t *testing.T, s *Storage) {
+		ctx := context.Background()
+		settings := NewSettingStorage(s.DB)
+
+		first, err := settings.GetOrCreate(ctx, model.SettingAuditSalt, "generated-1")
+		if err != nil || first != "generated-1" {
+			t.Fatalf("GetOrCreate() of the new setting = %q, %v, want %q, nil", first, err, "generated-1")
+		}
+
+		// The value generated by the next start is ignored, the salt stays stable
+		second, err := settings.GetOrCreate(ctx, model.SettingAuditSalt, "generated-2")
+		if err != nil || second != "generated-1" {
+			t.Errorf("GetOrCreate() of the stored setting = %q, %v, want %q, nil", second, err, "generated-1")
+		}
+	})
+}
+
+// legacyUser, legacyAccount and legacySnapshot are the tables before the money was stored in tyiyn.
+type legacyUser struct {
+	ID           int    `gorm:"primaryKey"`
+	TelegramID   int64  `gorm:"unique"`
+	AuthUsername string `gorm:"unique"`
+	AuthPassword string
+	Session      string
+	Accounts     []legacyAccount `gorm:"foreignKey:UserID"`
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacyAccount struct {
+	ID           int `gorm:"primaryKey"`
+	UserID       int
+	Number       string `gorm:"unique"`
+	BillingFrom  time.Time
+	BillingTo    time.Time
+	TariffAmount int
+	Balance      float64
+}
+
+func (legacyAccount) TableName() string { return "accounts" }
+
+type legacySnapshot struct {
+	ID           int `gorm:"primaryKey"`
+	AccountID    int `gorm:"index"`
+	Balance      float64
+	TariffAmount int
+	BillingFrom  time.Time
+	BillingTo    time.Time
+	CreatedAt    time.Time
+}
+
+func (legacySnapshot) TableName() string { return "balance_snapshots" }
+
+func TestMigrateMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     float64
+		tariff      int
+		wantBalance model.Money
+		wantTariff  model.Money
+	}{
+		{name: "whole soms", balance: 950, tariff: 950, wantBalance: model.Som(950), wantTariff: model.Som(950)},
+		{name: "float rounding", balance: 123.45, tariff: 500, wantBalance: model.Tyiyn(12345), wantTariff: model.Som(500)},
+		{name: "negative balance", balance: -12.5, tariff: 300, wantBalance: model.Tyiyn(-1250), wantTariff: model.Som(300)},
+		{name: "zero", balance: 0, tariff: 0, wantBalance: model.Tyiyn(0), wantTariff: model.Tyiyn(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runOnEmptyDrivers(t, func(t *testing.T, s *Storage) {
+				if err := s.DB.AutoMigrate(legacyUser{}, legacyAccount{}, legacySnapshot{}); err != nil {
+					t.Fatalf("create legacy tables: %v", err)
+				}
+
+				mustCreate(t, s, &legacyUser{ID: 1, TelegramID: 1, AuthUsername: "0555000001"})
+				mustCreate(t, s, &legacyAccount{UserID: 1, Number: "996555000001", TariffAmount: tt.tariff, Balance: tt.balance})
+				mustCreate(t, s, &legacySnapshot{AccountID: 1, TariffAmount: tt.tariff, Balance: tt.balance})
+
+				// The second migration finds no legacy columns and keeps the converted amounts
+				s.MustMigration()
+				s.MustMigration()
+
+				for _, table := range []string{"accounts", "balance_snapshots"} {
+					for _, column := range []string{"balance", "tariff_amount"} {
+						if s.DB.Migrator().HasColumn(table, column) {
+							t.Errorf("%s.%s is kept, want it dropped", table, column)
+						}
+					}
+				}
+
+				var account model.Account
+				if err := s.DB.First(&account).Error; err != nil {
+					t.Fatalf("load account: %v", err)
+				}
+
+				if account.Balance != tt.wantBalance || account.TariffAmount != tt.wantTariff {
+					t.Errorf("account balance, tariff = %+v, %+v, want %+v, %+v", account.Balance, account.TariffAmount, tt.wantBalance, tt.wantTariff)
+				}
+
+				var snapshot model.BalanceSnapshot
+				if err := s.DB.First(&snapshot).Error; err != nil {
+					t.Fatalf("load snapshot: %v", err)
+				}
+
+				if snapshot.Balance != tt.wantBalance || snapshot.TariffAmount != tt.wantTariff {
+					t.Errorf("snapshot balance, tariff = %+v, %+v, want %+v, %+v", snapshot.Balance, snapshot.TariffAmount, tt.wantBalance, tt.wantTariff)
+				}
+			})
+		})
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"gorm.io/gorm"
@@ -19,30 +20,99 @@ func NewUserStorage(db *gorm.DB) *UserStorage {
 
 func (s *UserStorage) GetOrCreateByTelegramID(ctx context.Context, userID int64) (*model.User, bool, error) {
 	var user model.User
-	if err := s.db.WithContext(ctx).Where("telegram_id = ?", userID).Preload("Accounts").First(&user).Error; err != nil {
-		if err = s.db.WithContext(ctx).Create(&model.User{TelegramID: userID}).Error; err != nil {
-			return nil, false, err
+	err := conn(ctx, s.db).Where("telegram_id = ?", userID).Preload("Accounts").First(&user).Error
+	if err == nil {
+		return &user, false, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	user = model.User{TelegramID: userID}
+	if err = conn(ctx, s.db).Create(&user).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &user, true, nil
+}
+
+// GetByTelegramID returns the user with the accounts, ErrNotFound is returned if the user doesn't exist.
+func (s *UserStorage) GetByTelegramID(ctx context.Context, userID int64) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, s.db).Where("telegram_id = ?", userID).Preload("Accounts").First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetByCalendarTokenHash returns the user with the accounts by the hash of the calendar feed token, ErrNotFound is
+// returned if no user has the token.
+func (s *UserStorage) GetByCalendarTokenHash(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	if err := conn(ctx, s.db).Where("calendar_token_hash = ?", tokenHash).Preload("Accounts").First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
 
-		return &user, true, nil
+		return nil, err
 	}
 
-	return &user, false, nil
+	return &user, nil
+}
+
+// SetCalendarTokenHash replaces the hash of the calendar feed token of the user, nil revokes the feed.
+func (s *UserStorage) SetCalendarTokenHash(ctx context.Context, userID int64, tokenHash *string) error {
+	return conn(ctx, s.db).Model(&model.User{}).Where("telegram_id = ?", userID).Update("calendar_token_hash", tokenHash).Error
+}
+
+// ListWithAccounts returns all the users with their accounts.
+func (s *UserStorage) ListWithAccounts(ctx context.Context) ([]model.User, error) {
+	var users []model.User
+	err := conn(ctx, s.db).Preload("Accounts").Order("id").Find(&users).Error
+	return users, err
 }
 
 func (s *UserStorage) Save(ctx context.Context, user *model.User) error {
-	return s.db.WithContext(ctx).Save(user).Error
+	return conn(ctx, s.db).Save(user).Error
 }
 
+// DeleteByTelegramID deletes the user with the accounts, their history, payments and notifications in a single
+// transaction. ErrNotFound is returned if the user doesn't exist.
 func (s *UserStorage) DeleteByTelegramID(ctx context.Context, userID int64) error {
-	subQuery := s.db.Model(&model.User{}).Select("id").Where("telegram_id = ?", userID)
-	if err := s.db.Where("user_id IN (?)", subQuery).Delete(&model.Account{}).Error; err != nil {
-		return fmt.Errorf("delete accounts: %w", err)
-	}
+	return conn(ctx, s.db).Transaction(func(tx *gorm.DB) error {
+		subQuery := tx.Model(&model.User{}).Select("id").Where("telegram_id = ?", userID)
+		accountsQuery := tx.Model(&model.Account{}).Select("id").Where("user_id IN (?)", subQuery)
+		if err := tx.Where("account_id IN (?)", accountsQuery).Delete(&model.BalanceSnapshot{}).Error; err != nil {
+			return fmt.Errorf("delete snapshots: %w", err)
+		}
 
-	if err := s.db.WithContext(ctx).Where("telegram_id = ?", userID).Delete(&model.User{}).Error; err != nil {
-		return fmt.Errorf("delete user: %w", err)
-	}
+		if err := tx.Where("account_id IN (?)", accountsQuery).Delete(&model.Payment{}).Error; err != nil {
+			return fmt.Errorf("delete payments: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&model.Notification{}).Error; err != nil {
+			return fmt.Errorf("delete notifications: %w", err)
+		}
+
+		if err := tx.Where("user_id IN (?)", subQuery).Delete(&model.Account{}).Error; err != nil {
+			return fmt.Errorf("delete accounts: %w", err)
+		}
+
+		result := tx.Where("telegram_id = ?", userID).Delete(&model.User{})
+		if result.Error != nil {
+			return fmt.Errorf("delete user: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type alertPaymentStorage interface {
+	ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.Payment, error)
+	ListByAccountBetween(ctx context.Context, accountID int, after, until time.Time) ([]model.Payment, error)
+}
+
+// AccountRefresh is what a refresh observed for the account: the state before the refresh and the saved one.
+type AccountRefresh struct {
+	UserID int64
+	// Account is the account saved by the refresh.
+	Account model.Account
+	// PreviousSnapshot is nil for the first snapshot of the account, Current is the snapshot of the refresh.
+	PreviousSnapshot *model.BalanceSnapshot
+	Current          model.BalanceSnapshot
+
+	PreviousStatus     model.AccountStatus
+	PreviousBilling    model.BillingPeriod
+	PreviousTariff     model.Money
+	PreviousTariffName string
+}
+
+// AlertUseCase tells the owners about what the balance refresh observed: the blocked and restored accounts,
+// the period digests, the unexpected charges and the tariff changes. The alerts are enqueued to the outbox, keyed
+// by the change they tell about.
+type AlertUseCase struct {
+	logger         *slog.Logger
+	location       *time.Location
+	paymentStorage alertPaymentStorage
+	forecaster     *ForecastUseCase
+	outbox         outbox
+}
+
+func NewAlertUseCase(logger *slog.Logger, location *time.Location, paymentStorage alertPaymentStorage, outbox outbox) *AlertUseCase {
+	return &AlertUseCase{
+		logger:         logger.With("use_case", "AlertUseCase"),
+		location:       location,
+		paymentStorage: paymentStorage,
+		forecaster:     NewForecastUseCase(location),
+		outbox:         outbox,
+	}
+}
+
+// Alert enqueues the alerts about the refresh of the account. It is called in the transaction saving the refresh,
+// so the alert is enqueued if and only if the change it tells about is saved.
+func (uc *AlertUseCase) Alert(ctx context.Context, refresh AccountRefresh) error {
+	for _, alert := range []func(ctx context.Context, refresh AccountRefresh) error{
+		uc.alertStatusChange,
+		uc.alertPeriodDigest,
+		uc.alertChargeAnomaly,
+		uc.alertTariffChange,
+	} {
+		if err := alert(ctx, refresh); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// alertStatusChange tells the owner that the service of the account was blocked or restored.
+func (uc *AlertUseCase) alertStatusChange(ctx context.Context, refresh AccountRefresh) error {
+	if !isNotableStatusChange(refresh.PreviousStatus, refresh.Account.Status) {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s:%d", model.NotificationKindStatusChanged, refresh.Current.ID)
+	payload := StatusChange{Account: summarizeAccount(refresh.Account), Previous: refresh.PreviousStatus}
+	if err := uc.outbox.Enqueue(ctx, refresh.UserID, model.NotificationKindStatusChanged, key, payload); err != nil {
+		return fmt.Errorf("enqueue status change: %w", err)
+	}
+
+	return nil
+}
+
+// isNotableStatusChange reports whether the owner is told about the change: the account got blocked or got back
+// to active after being blocked. The first status seen is not a change.
+func isNotableStatusChange(previous, current model.AccountStatus) bool {
+	switch {
+	case previous == "" || previous == current:
+		return false
+	case current == model.AccountStatusBlocked:
+		return true
+	default:
+		return previous == model.AccountStatusBlocked && current == model.AccountStatusActive
+	}
+}
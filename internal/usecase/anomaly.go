@@ -0,0 +1,122 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// maxExpectedRollovers bounds the number of the periods counted between two snapshots far apart.
+const maxExpectedRollovers = 24
+
+// ChargeAnomaly is the drop of the balance between two consecutive snapshots the charge model does not explain:
+// the tariff is charged once at the start of every period and nothing is charged in the middle of it.
+type ChargeAnomaly struct {
+	Account  AccountSummary
+	Previous model.BalanceSnapshot
+	Current  model.BalanceSnapshot
+	// Paid is the sum of the payments made between the snapshots.
+	Paid model.Money
+	// Charged is how much the balance dropped, the payments taken into account.
+	Charged model.Money
+	// Expected is the tariff charged for the periods started between the snapshots.
+	Expected model.Money
+	// Unexpected is the part of the charge the charge model does not explain.
+	Unexpected model.Money
+}
+
+// SetAnomalySensitivity sets how large the unexpected charge of the account of the user must be to alert them.
+func (uc *BalanceUseCase) SetAnomalySensitivity(ctx context.Context, userID int64, accountNumber string, sensitivity model.AnomalySensitivity) error {
+	log := uc.logger.With("method", "SetAnomalySensitivity", "user_id", userID)
+
+	account, err := uc.accountOf(ctx, log, userID, accountNumber)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.accountStorage.SetAnomalySensitivity(ctx, account.ID, sensitivity); err != nil {
+		log.Error("set anomaly sensitivity", "error", err)
+		return fmt.Errorf("set anomaly sensitivity: %w", err)
+	}
+
+	return nil
+}
+
+// alertChargeAnomaly alerts the owner of the account if the balance dropped since the previous snapshot more than
+// the charge model expects at the sensitivity of the account.
+func (uc *AlertUseCase) alertChargeAnomaly(ctx context.Context, refresh AccountRefresh) error {
+	if refresh.PreviousSnapshot == nil {
+		return nil
+	}
+
+	paid, err := uc.paidBetween(ctx, *refresh.PreviousSnapshot, refresh.Current)
+	if err != nil {
+		return err
+	}
+
+	anomaly, ok := detectChargeAnomaly(*refresh.PreviousSnapshot, refresh.Current, paid, refresh.Account.AnomalySensitivity)
+	if !ok {
+		return nil
+	}
+
+	anomaly.Account = summarizeAccount(refresh.Account)
+
+	uc.logger.Warn("unexpected charge", "method", "alertChargeAnomaly", "user_id", refresh.UserID, "account", refresh.Account.Number,
+		"charged", anomaly.Charged, "expected", anomaly.Expected)
+
+	key := fmt.Sprintf("%s:%d", model.NotificationKindChargeAnomaly, refresh.Current.ID)
+	if err = uc.outbox.Enqueue(ctx, refresh.UserID, model.NotificationKindChargeAnomaly, key, anomaly); err != nil {
+		return fmt.Errorf("enqueue unexpected charge: %w", err)
+	}
+
+	return nil
+}
+
+// paidBetween returns the sum of the stored payments made after the previous snapshot up to the current one. The
+// payments are taken by the date they were made, so a payment the personal cabinet lists late is not added to the
+// refresh that stored it.
+func (uc *AlertUseCase) paidBetween(ctx context.Context, previous, current model.BalanceSnapshot) (model.Money, error) {
+	payments, err := uc.paymentStorage.ListByAccountBetween(ctx, current.AccountID, previous.CreatedAt, current.CreatedAt)
+	if err != nil {
+		return model.Money{}, fmt.Errorf("list payments: %w", err)
+	}
+
+	paid := model.Money{}
+	for _, payment := range payments {
+		paid = paid.Add(payment.Amount)
+	}
+
+	return paid, nil
+}
+
+// detectChargeAnomaly compares the consecutive snapshots against the charge model, the payments made between them
+// are added back to the balance. It reports whether the unexpected charge reaches the threshold of the sensitivity.
+func detectChargeAnomaly(previous, current model.BalanceSnapshot, paid model.Money, sensitivity model.AnomalySensitivity) (ChargeAnomaly, bool) {
+	threshold, ok := sensitivity.Threshold(current.TariffAmount)
+	if !ok || previous.Billing.IsZero() || current.Billing.IsZero() {
+		return ChargeAnomaly{}, false
+	}
+
+	anomaly := ChargeAnomaly{
+		Previous: previous,
+		Current:  current,
+		Paid:     paid,
+		Charged:  previous.Balance.Add(paid).Sub(current.Balance),
+		Expected: current.TariffAmount.Mul(int64(rolloversBetween(previous.Billing, current.Billing))),
+	}
+
+	anomaly.Unexpected = anomaly.Charged.Sub(anomaly.Expected)
+
+	return anomaly, anomaly.Unexpected.Cmp(threshold) >= 0
+}
+
+// rolloversBetween returns the number of the periods started after the previous period up to the current one.
+func rolloversBetween(previous, current model.BillingPeriod) int {
+	rollovers := 0
+	for period := previous; rollovers < maxExpectedRollovers && period.From.Before(current.From); rollovers++ {
+		period = period.Next()
+	}
+
+	return rollovers
+}
@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type enqueued struct {
+	userID  int64
+	kind    model.NotificationKind
+	key     string
+	payload any
+}
+
+type fakeOutbox struct {
+	enqueued []enqueued
+}
+
+func (o *fakeOutbox) Enqueue(_ context.Context, userID int64, kind model.NotificationKind, key string, payload any) error {
+	o.enqueued = append(o.enqueued, enqueued{userID: userID, kind: kind, key: key, payload: payload})
+	return nil
+}
+
+func TestRolloversBetween(t *testing.T) {
+	october := model.BillingPeriod{From: utcDay(10, 1), To: utcDay(10, 31)}
+
+	tests := []struct {
+		name     string
+		previous model.BillingPeriod
+		current  model.BillingPeriod
+		want     int
+	}{
+		{name: "same period", previous: october, current: october, want: 0},
+		{name: "next period", previous: october, current: october.Next(), want: 1},
+		{name: "two periods later", previous: october, current: october.Next().Next(), want: 2},
+		{name: "period moved back", previous: october.Next(), current: october, want: 0},
+		{
+			name:     "bounded for the periods far apart",
+			previous: october,
+			current:  model.BillingPeriod{From: october.From.AddDate(5, 0, 0), To: october.To.AddDate(5, 0, 0)},
+			want:     maxExpectedRollovers,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rolloversBetween(tt.previous, tt.current); got != tt.want {
+				t.Errorf("rolloversBetween() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectChargeAnomaly(t *testing.T) {
+	october := model.BillingPeriod{From: utcDay(10, 1), To: utcDay(10, 31)}
+	november := october.Next()
+
+	tests := []struct {
+		name           string
+		previous       *model.BalanceSnapshot
+		current        *model.BalanceSnapshot
+		paid           model.Money
+		sensitivity    model.AnomalySensitivity
+		wantOK         bool
+		wantUnexpected model.Money
+	}{
+		{
+			name:           "tariff charged at the rollover",
+			previous:       snapshotAt(utcDay(10, 30), model.Som(1000), october),
+			current:        snapshotAt(utcDay(11, 1), model.Som(50), november),
+			wantUnexpected: model.Som(0),
+		},
+		{
+			name:           "rollover charged more than the tariff",
+			previous:       snapshotAt(utcDay(10, 30), model.Som(1000), october),
+			current:        snapshotAt(utcDay(11, 1), model.Som(-150), november),
+			wantOK:         true,
+			wantUnexpected: model.Som(200),
+		},
+		{
+			name:           "drop in the middle of the period",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), october),
+			current:        snapshotAt(utcDay(10, 11), model.Som(900), october),
+			wantOK:         true,
+			wantUnexpected: model.Som(100),
+		},
+		{
+			name:           "drop below the threshold",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), october),
+			current:        snapshotAt(utcDay(10, 11), model.Som(950), october),
+			wantUnexpected: model.Som(50),
+		},
+		{
+			name:           "any drop at high sensitivity",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), october),
+			current:        snapshotAt(utcDay(10, 11), model.Tyiyn(99999), october),
+			sensitivity:    model.AnomalySensitivityHigh,
+			wantOK:         true,
+			wantUnexpected: model.Tyiyn(1),
+		},
+		{
+			name:           "payment between the snapshots added back",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), october),
+			current:        snapshotAt(utcDay(10, 11), model.Som(1400), october),
+			paid:           model.Som(500),
+			wantOK:         true,
+			wantUnexpected: model.Som(100),
+		},
+		{
+			name:           "alerts off",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), october),
+			current:        snapshotAt(utcDay(10, 11), model.Som(0), october),
+			sensitivity:    model.AnomalySensitivityOff,
+			wantUnexpected: model.Som(0),
+		},
+		{
+			name:           "unknown billing period",
+			previous:       snapshotAt(utcDay(10, 10), model.Som(1000), model.BillingPeriod{}),
+			current:        snapshotAt(utcDay(10, 11), model.Som(0), october),
+			wantUnexpected: model.Som(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomaly, ok := detectChargeAnomaly(*tt.previous, *tt.current, tt.paid, tt.sensitivity)
+			if ok != tt.wantOK || anomaly.Unexpected.Cmp(tt.wantUnexpected) != 0 {
+				t.Errorf("detectChargeAnomaly() = %v, %v, want %v, %v", anomaly.Unexpected, ok, tt.wantUnexpected, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAlertChargeAnomalyPaidBetweenSnapshots(t *testing.T) {
+	october := model.BillingPeriod{From: utcDay(10, 1), To: utcDay(10, 31)}
+	previous := snapshotAt(utcDay(10, 11), model.Som(1000), october)
+	current := snapshotAt(utcDay(10, 12), model.Som(500), october)
+	current.ID = 7
+
+	tests := []struct {
+		name     string
+		payments []model.Payment
+		wantPaid model.Money
+	}{
+		{
+			name:     "charge without payments",
+			wantPaid: model.Money{},
+		},
+		{
+			name:     "payment made between the snapshots",
+			payments: []model.Payment{model.NewPayment(1, utcDay(10, 11).Add(time.Hour), model.Som(300), "Элсом")},
+			wantPaid: model.Som(300),
+		},
+		{
+			name: "payment listed late is not added back",
+			payments: []model.Payment{
+				model.NewPayment(1, utcDay(10, 10), model.Som(500), "Терминал"),
+				model.NewPayment(1, utcDay(10, 11), model.Som(100), "Терминал"),
+			},
+			wantPaid: model.Money{},
+		},
+		{
+			name:     "payment made after the current snapshot",
+			payments: []model.Payment{model.NewPayment(1, utcDay(10, 12).Add(time.Hour), model.Som(200), "Элсом")},
+			wantPaid: model.Money{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := &fakeOutbox{}
+			uc := NewAlertUseCase(discardLogger, time.UTC, &fakePaymentStorage{payments: tt.payments}, outbox)
+
+			err := uc.alertChargeAnomaly(context.Background(), AccountRefresh{
+				UserID:           100,
+				Account:          model.Account{ID: 1, Number: "996555000001"},
+				PreviousSnapshot: previous,
+				Current:          *current,
+			})
+			if err != nil {
+				t.Fatalf("alertChargeAnomaly() error = %v", err)
+			}
+
+			if len(outbox.enqueued) != 1 {
+				t.Fatalf("enqueued %d alerts, want 1", len(outbox.enqueued))
+			}
+
+			anomaly := outbox.enqueued[0].payload.(ChargeAnomaly)
+			if anomaly.Paid.Cmp(tt.wantPaid) != 0 {
+				t.Errorf("paid = %v, want %v", anomaly.Paid, tt.wantPaid)
+			}
+
+			if outbox.enqueued[0].key != "charge_anomaly:7" {
+				t.Errorf("key = %q, want %q", outbox.enqueued[0].key, "charge_anomaly:7")
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const retentionInterval = 24 * time.Hour
+
+type auditStorage interface {
+	Create(ctx context.Context, event *model.AuditEvent) error
+	List(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error)
+	DeleteOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+// auditor is the part of AuditUseCase the other use cases record their actions with.
+type auditor interface {
+	Record(ctx context.Context, action string, userID int64, details string) error
+}
+
+type AuditUseCase struct {
+	logger       *slog.Logger
+	salt         string
+	retention    time.Duration
+	auditStorage auditStorage
+}
+
+func NewAuditUseCase(logger *slog.Logger, salt string, retention time.Duration, auditStorage auditStorage) *AuditUseCase {
+	return &AuditUseCase{
+		logger:       logger.With("use_case", "AuditUseCase"),
+		salt:         salt,
+		retention:    retention,
+		auditStorage: auditStorage,
+	}
+}
+
+// Record appends the action of the user to the audit log. When called inside a transaction, the event is committed
+// or rolled back together with it.
+func (uc *AuditUseCase) Record(ctx context.Context, action string, userID int64, details string) error {
+	event := &model.AuditEvent{
+		Action:    action,
+		ActorHash: uc.HashTelegramID(userID),
+		Details:   details,
+	}
+
+	if err := uc.auditStorage.Create(ctx, event); err != nil {
+		uc.logger.Error("create audit event", "error", err, "action", action)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns the newest events of the action and the user, the empty action and the zero user match all.
+func (uc *AuditUseCase) Query(ctx context.Context, action string, userID int64, limit int) ([]model.AuditEvent, error) {
+	filter := model.AuditFilter{Action: action, Limit: limit}
+	if userID != 0 {
+		filter.ActorHash = uc.HashTelegramID(userID)
+	}
+
+	events, err := uc.auditStorage.List(ctx, filter)
+	if err != nil {
+		uc.logger.Error("list audit events", "error", err)
+		return nil, fmt.Errorf("list audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// HashTelegramID returns the keyed hash of the Telegram ID the events are stored with.
+func (uc *AuditUseCase) HashTelegramID(userID int64) string {
+	return keyedHash(uc.salt, strconv.FormatInt(userID, 10))
+}
+
+// keyedHash returns the HMAC of the value. A plain hash is not enough for the identifiers like the Telegram IDs,
+// their space is small enough to be enumerated.
+func keyedHash(salt, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RunRetention removes the events past the retention period once a day until the context is canceled. The zero
+// retention keeps the events forever.
+func (uc *AuditUseCase) RunRetention(ctx context.Context) {
+	if uc.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		uc.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (uc *AuditUseCase) purge(ctx context.Context) {
+	log := uc.logger.With("method", "purge")
+
+	deleted, err := uc.auditStorage.DeleteOlderThan(ctx, time.Now().Add(-uc.retention))
+	if err != nil {
+		log.Error("delete old audit events", "error", err)
+		return
+	}
+
+	log.Info("Deleted old audit events", "deleted", deleted)
+}
@@ -5,52 +5,160 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"regexp"
-	"strconv"
-	"strings"
+	"slices"
+	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
-
+	"github.com/aastashov/megalinekg_bot/internal/event"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
 	"github.com/aastashov/megalinekg_bot/internal/model"
-)
-
-var (
-	dateRe = regexp.MustCompile(`\b(\d{2})\.(\d{2})\.(\d{4})\b`)
+	"github.com/aastashov/megalinekg_bot/internal/storage"
 )
 
 type userStorage interface {
 	GetOrCreateByTelegramID(ctx context.Context, userID int64) (*model.User, bool, error)
+	ListWithAccounts(ctx context.Context) ([]model.User, error)
 	Save(ctx context.Context, user *model.User) error
 }
 
 type accountStorage interface {
 	Save(ctx context.Context, account *model.Account) error
+	DeleteByIDs(ctx context.Context, accountIDs []int) error
+	SetDigestEnabled(ctx context.Context, accountID int, enabled bool) error
+	SetAnomalySensitivity(ctx context.Context, accountID int, sensitivity model.AnomalySensitivity) error
+}
+
+type snapshotStorage interface {
+	Create(ctx context.Context, snapshot *model.BalanceSnapshot) error
+	Latest(ctx context.Context, accountID int) (*model.BalanceSnapshot, error)
+}
+
+type paymentStorage interface {
+	CreateIfNew(ctx context.Context, payment *model.Payment) (bool, error)
+	ListRecent(ctx context.Context, accountID int, limit int) ([]model.Payment, error)
+	ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.Payment, error)
 }
 
 type megaLine interface {
-	Login(ctx context.Context, username, password string) ([]byte, string, error)
+	Login(ctx context.Context, username, password string) (*megaline.LoginResult, error)
+	SubmitCaptcha(ctx context.Context, session, username, password string, captcha *megaline.Captcha, answer string) (*megaline.LoginResult, error)
 	GetAccountsDetail(ctx context.Context, session, account string) ([]byte, error)
+	GetPayments(ctx context.Context, session, account string) ([]byte, error)
+	GetProfile(ctx context.Context, session, account string) ([]byte, error)
+	RequestPromisedPayment(ctx context.Context, session, account string) (megaline.PromisedPaymentStatus, error)
+}
+
+type eventPublisher interface {
+	Publish(ctx context.Context, event event.Event)
+}
+
+type refreshAlerter interface {
+	Alert(ctx context.Context, refresh AccountRefresh) error
+}
+
+type loginGuard interface {
+	Check(ctx context.Context, userID int64, login string) error
+	Fail(ctx context.Context, userID int64, login string) error
+	Succeed(ctx context.Context, userID int64, login string) error
 }
 
 type BalanceUseCase struct {
-	logger         *slog.Logger
-	userStorage    userStorage
-	accountStorage accountStorage
-	megaLine       megaLine
+	logger          *slog.Logger
+	transactor      transactor
+	userStorage     userStorage
+	accountStorage  accountStorage
+	snapshotStorage snapshotStorage
+	paymentStorage  paymentStorage
+	megaLine        megaLine
+	loginGuard      loginGuard
+	auditor         auditor
+	location        *time.Location
+	alerts          refreshAlerter
+	events          eventPublisher
+
+	pendingCaptchasMu sync.Mutex
+	pendingCaptchas   map[int64]pendingCaptcha
+	captchaRequired   map[int64]struct{}
 }
 
-func NewBalanceUseCase(logger *slog.Logger, userStorage userStorage, accountStorage accountStorage, megaLine megaLine) *BalanceUseCase {
+func NewBalanceUseCase(logger *slog.Logger, transactor transactor, userStorage userStorage, accountStorage accountStorage, snapshotStorage snapshotStorage, paymentStorage paymentStorage, megaLine megaLine, loginGuard loginGuard, auditor auditor, location *time.Location, alerts refreshAlerter, events eventPublisher) *BalanceUseCase {
 	return &BalanceUseCase{
-		logger:         logger.With("use_case", "BalanceUseCase"),
-		userStorage:    userStorage,
-		accountStorage: accountStorage,
-		megaLine:       megaLine,
+		logger:          logger.With("use_case", "BalanceUseCase"),
+		transactor:      transactor,
+		userStorage:     userStorage,
+		accountStorage:  accountStorage,
+		snapshotStorage: snapshotStorage,
+		paymentStorage:  paymentStorage,
+		megaLine:        megaLine,
+		loginGuard:      loginGuard,
+		auditor:         auditor,
+		location:        location,
+		alerts:          alerts,
+		events:          events,
+		pendingCaptchas: make(map[int64]pendingCaptcha),
+		captchaRequired: make(map[int64]struct{}),
+	}
+}
+
+// RunRefresh refreshes the balance of every user with the saved credentials at the interval until the context is
+// canceled, so that the changes are noticed without the user asking. The zero interval disables the refresh.
+func (uc *BalanceUseCase) RunRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		uc.refreshAll(ctx)
 	}
 }
 
+// refreshAll refreshes the balance of every user with the saved credentials. Nobody is there to answer a captcha
+// in the background, so the users whose login needs one are skipped until they refresh the balance themselves, and
+// the failed logins don't count towards the lockout of the user.
+func (uc *BalanceUseCase) refreshAll(ctx context.Context) {
+	users, err := uc.userStorage.ListWithAccounts(ctx)
+	if err != nil {
+		uc.logger.Error("list users", "method", "RunRefresh", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		if user.AuthUsername == "" || user.AuthPassword == "" || uc.awaitsCaptcha(user.TelegramID) {
+			continue
+		}
+
+		// The errors are logged by updateBalance
+		_ = uc.updateBalance(ctx, user.TelegramID, false)
+	}
+}
+
+// UpdateBalance refreshes the balance of the user on their request. CaptchaRequiredError is returned when the user
+// has to answer a captcha to log in.
 func (uc *BalanceUseCase) UpdateBalance(ctx context.Context, userID int64) error {
-	log := uc.logger.With("method", "UpdateBalance", "user_id", userID)
+	return uc.updateBalance(ctx, userID, true)
+}
+
+// updateBalance logs in if there is no session and refreshes the accounts, the expired session is replaced by the
+// repeated login once. Only the interactive refresh keeps the captcha for the user to answer and counts the failed
+// login towards the lockout.
+func (uc *BalanceUseCase) updateBalance(ctx context.Context, userID int64, interactive bool) (err error) {
+	log := uc.logger.With("method", "UpdateBalance", "user_id", userID, "interactive", interactive)
+
+	startedAt := time.Now()
+	defer func() {
+		metrics.RefreshDuration.Observe(time.Since(startedAt).Seconds())
+		metrics.RefreshResults.WithLabelValues(refreshResult(err)).Inc()
+	}()
 
 	user, _, err := uc.userStorage.GetOrCreateByTelegramID(ctx, userID)
 	if err != nil {
@@ -63,120 +171,359 @@ func (uc *BalanceUseCase) UpdateBalance(ctx context.Context, userID int64) error
 		return errors.New("user not authorized")
 	}
 
-	if user.Session == "" {
-		body, sessionID, err := uc.megaLine.Login(ctx, user.AuthUsername, user.AuthPassword)
-		if err != nil {
-			log.Error("login", "error", err, "response.body", string(body))
-			return fmt.Errorf("login: %w", err)
+	// Only the stored session may have expired, the login is repeated once to replace it
+	stored := user.Session != ""
+	if !stored {
+		if err = uc.login(ctx, log, user, interactive); err != nil {
+			return err
 		}
+	}
+
+	err = uc.refreshAccounts(ctx, log, user)
+	if !stored || !errors.Is(err, megaline.ErrSessionExpired) {
+		return err
+	}
 
-		if !strings.Contains(string(body), "Лицевой счет №") {
-			log.Error("login failed", "response.body", string(body))
-			return errors.New("login failed")
+	if err = uc.login(ctx, log, user, interactive); err != nil {
+		return err
+	}
+
+	return uc.refreshAccounts(ctx, log, user)
+}
+
+// login logs in to the personal cabinet with the credentials of the user unless the lockout forbids it.
+func (uc *BalanceUseCase) login(ctx context.Context, log *slog.Logger, user *model.User, interactive bool) error {
+	if err := uc.loginGuard.Check(ctx, user.TelegramID, user.AuthUsername); err != nil {
+		log.Warn("login is not allowed", "error", err)
+		return fmt.Errorf("check login attempts: %w", err)
+	}
+
+	result, err := uc.megaLine.Login(ctx, user.AuthUsername, user.AuthPassword)
+	if err != nil {
+		log.Error("login", "error", err)
+		uc.loginFailed(ctx, user.TelegramID, "request failed")
+		return fmt.Errorf("login: %w", err)
+	}
+
+	return uc.applyLogin(ctx, log, user, result, interactive)
+}
+
+// SolveCaptcha submits the answer to the captcha returned by UpdateBalance in CaptchaRequiredError and finishes
+// the refresh. A wrong answer results in another CaptchaRequiredError.
+func (uc *BalanceUseCase) SolveCaptcha(ctx context.Context, userID int64, answer string) (err error) {
+	log := uc.logger.With("method", "SolveCaptcha", "user_id", userID)
+
+	startedAt := time.Now()
+	defer func() {
+		metrics.RefreshDuration.Observe(time.Since(startedAt).Seconds())
+		metrics.RefreshResults.WithLabelValues(refreshResult(err)).Inc()
+	}()
+
+	pending, ok := uc.takePendingCaptcha(userID)
+	if !ok {
+		return ErrNoPendingCaptcha
+	}
+
+	user, _, err := uc.userStorage.GetOrCreateByTelegramID(ctx, userID)
+	if err != nil {
+		log.Error("get user by telegram ID", "error", err)
+		return fmt.Errorf("get user by telegram ID: %w", err)
+	}
+
+	// The answers count as the login attempts, the lockout must not be bypassed by answering the captchas
+	if err = uc.loginGuard.Check(ctx, userID, user.AuthUsername); err != nil {
+		log.Warn("login is not allowed", "error", err)
+		return fmt.Errorf("check login attempts: %w", err)
+	}
+
+	result, err := uc.megaLine.SubmitCaptcha(ctx, pending.session, user.AuthUsername, user.AuthPassword, pending.captcha, answer)
+	if err != nil {
+		log.Error("submit captcha", "error", err)
+		uc.loginFailed(ctx, userID, "request failed")
+		return fmt.Errorf("submit captcha: %w", err)
+	}
+
+	if err = uc.applyLogin(ctx, log, user, result, true); err != nil {
+		return err
+	}
+
+	return uc.refreshAccounts(ctx, log, user)
+}
+
+// applyLogin stores the session and the accounts of the successful login, any other outcome is turned into
+// the error describing it. The captcha of the background login is not kept, only the user can answer it.
+func (uc *BalanceUseCase) applyLogin(ctx context.Context, log *slog.Logger, user *model.User, result *megaline.LoginResult, interactive bool) error {
+	log = log.With("login_status", result.Status)
+
+	switch result.Status {
+	case megaline.LoginStatusSuccess:
+	case megaline.LoginStatusCaptcha:
+		log.Warn("login requires captcha")
+		if interactive {
+			uc.storePendingCaptcha(user.TelegramID, result.Session, result.Captcha)
+		} else {
+			uc.markCaptchaRequired(user.TelegramID)
+		}
+
+		return &CaptchaRequiredError{Image: result.Captcha.Image}
+	case megaline.LoginStatusBadCredentials:
+		log.Error("login failed")
+		uc.loginFailed(ctx, user.TelegramID, "bad credentials")
+		if interactive {
+			_ = uc.loginGuard.Fail(ctx, user.TelegramID, user.AuthUsername)
 		}
 
-		user.Session = sessionID
+		return ErrBadCredentials
+	case megaline.LoginStatusBlocked:
+		log.Error("login blocked by MegaLine")
+		uc.loginFailed(ctx, user.TelegramID, "blocked by MegaLine")
+		return ErrMegaLineBlocked
+	case megaline.LoginStatusMaintenance:
+		log.Warn("MegaLine is under maintenance")
+		return ErrMegaLineMaintenance
+	default:
+		log.Error("login failed", "response.body", string(result.Body))
+		uc.loginFailed(ctx, user.TelegramID, "unknown response")
+		return errors.New("login failed")
+	}
 
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
-		if err != nil {
-			log.Error("parse login response", "error", err)
-			return fmt.Errorf("parse login response: %w", err)
+	_ = uc.auditor.Record(ctx, model.AuditActionLoginSucceeded, user.TelegramID, "")
+	_ = uc.loginGuard.Succeed(ctx, user.TelegramID, user.AuthUsername)
+	uc.clearCaptchaRequired(user.TelegramID)
+
+	user.Session = result.Session
+
+	numbers, err := megaline.ParseAccountNumbers(result.Body)
+	if err != nil {
+		log.Error("parse login response", "error", err)
+		return fmt.Errorf("parse login response: %w", err)
+	}
+
+	// The accounts the login no longer lists, e.g. the accounts of the replaced credentials, are removed with their
+	// history. No accounts at all is rather the changed page than the login without accounts, nothing is removed then.
+	if len(numbers) > 0 {
+		var unlisted []int
+		user.Accounts = slices.DeleteFunc(user.Accounts, func(account model.Account) bool {
+			listed := slices.Contains(numbers, account.Number)
+			if !listed && account.ID != 0 {
+				unlisted = append(unlisted, account.ID)
+			}
+
+			return !listed
+		})
+
+		if len(unlisted) > 0 {
+			if err = uc.accountStorage.DeleteByIDs(ctx, unlisted); err != nil {
+				log.Error("delete unlisted accounts", "error", err)
+				return fmt.Errorf("delete unlisted accounts: %w", err)
+			}
+
+			log.Info("unlisted accounts deleted", "accounts", len(unlisted))
 		}
+	}
 
-		doc.Find(".account_selector").Find("option").Each(func(i int, s *goquery.Selection) {
-			user.Accounts = append(user.Accounts, model.Account{Number: strings.TrimSpace(s.Text()), UserID: user.ID})
+	// The accounts known from the previous logins keep their state, only the new ones are added
+	for _, number := range numbers {
+		known := slices.ContainsFunc(user.Accounts, func(account model.Account) bool {
+			return account.Number == number
 		})
+
+		if !known {
+			user.Accounts = append(user.Accounts, model.Account{Number: number, UserID: user.ID})
+		}
 	}
 
-	if err = uc.userStorage.Save(ctx, user); err != nil {
+	return nil
+}
+
+// refreshAccounts stores the accounts of the user with the balance, the snapshot and the payments observed now,
+// and publishes the events describing what changed since the previous refresh. The expired session is dropped and
+// megaline.ErrSessionExpired returned, the login page is not saved as the state of the account.
+func (uc *BalanceUseCase) refreshAccounts(ctx context.Context, log *slog.Logger, user *model.User) error {
+	discovered := make(map[string]bool, len(user.Accounts))
+	for _, account := range user.Accounts {
+		discovered[account.Number] = account.ID == 0
+	}
+
+	if err := uc.userStorage.Save(ctx, user); err != nil {
 		log.Error("save user", "error", err)
 		return fmt.Errorf("save user: %w", err)
 	}
 
+	for _, account := range user.Accounts {
+		if discovered[account.Number] {
+			uc.events.Publish(ctx, event.AccountDiscovered{UserID: user.TelegramID, Account: account})
+		}
+	}
+
 	for _, account := range user.Accounts {
 		body, err := uc.megaLine.GetAccountsDetail(ctx, user.Session, account.Number)
-		if err != nil {
-			log.Error("get account detail", "error", err)
-			continue
+		if err == nil {
+			body, err = uc.megaLine.GetAccountsDetail(ctx, user.Session, account.Number)
 		}
 
-		body, err = uc.megaLine.GetAccountsDetail(ctx, user.Session, account.Number)
-		if err != nil {
-			log.Error("get account detail", "error", err, "response.body", string(body))
-			continue
+		if errors.Is(err, megaline.ErrSessionExpired) {
+			log.Warn("session expired", "account", account.Number)
+			return uc.dropSession(ctx, log, user)
 		}
 
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
 		if err != nil {
-			log.Error("parse account detail", "error", err)
+			log.Error("get account detail", "error", err, "account", account.Number)
 			continue
 		}
 
-		doc.Find(".account_info").Find(".span100").Each(func(i int, s *goquery.Selection) {
-			switch strings.TrimSpace(s.Find(".desc").Text()) {
-			case "Баланс":
-				s.Find(".value").Each(func(i int, s *goquery.Selection) {
-					balance := strings.TrimSpace(s.Text())
-					balance = strings.ReplaceAll(balance, " ", "")
-					balance = strings.ReplaceAll(balance, "сом", "")
-					balance = strings.ReplaceAll(balance, ",", ".")
-
-					balanceFloat, err := strconv.ParseFloat(strings.TrimSpace(balance), 64)
-					if err != nil {
-						log.Error("Parse balance failed", "error", err, "balance", balance)
-						return
-					}
-
-					account.Balance = balanceFloat
-				})
-			case "Расчетный период:":
-				s.Find(".value").Each(func(i int, s *goquery.Selection) {
-					period := strings.TrimSpace(s.Text())
-					matches := dateRe.FindAllStringSubmatch(period, -1)
-
-					if len(matches) != 2 {
-						log.Error("Parse period failed", "period", period)
-						return
-					}
-
-					parsedDate, err := time.Parse("02.01.2006", matches[0][0])
-					if err != nil {
-						log.Error("Parse period failed", "error", err, "period", period)
-						return
-					}
-
-					account.BillingFrom = parsedDate
-
-					parsedDate, err = time.Parse("02.01.2006", matches[1][0])
-					if err != nil {
-						log.Error("Parse period failed", "error", err, "period", period)
-						return
-					}
-
-					account.BillingTo = parsedDate
-				})
-			case "Оплата за период:":
-				s.Find(".value").Each(func(i int, s *goquery.Selection) {
-					payment := strings.TrimSpace(s.Text())
-					payment = strings.ReplaceAll(payment, " ", "")
-					payment = strings.ReplaceAll(payment, "сом", "")
-
-					paymentInt, err := strconv.Atoi(strings.TrimSpace(payment))
-					if err != nil {
-						log.Error("Parse payment failed", "error", err, "payment", payment)
-						return
-					}
-
-					account.TariffAmount = paymentInt
-				})
-			}
-		})
+		refresh := AccountRefresh{
+			UserID:             user.TelegramID,
+			PreviousStatus:     account.Status,
+			PreviousBilling:    account.Billing,
+			PreviousTariff:     account.TariffAmount,
+			PreviousTariffName: account.TariffName,
+		}
+
+		if err = megaline.ParseAccountDetail(body, &account, uc.location); err != nil {
+			log.Error("parse account detail", "error", err, "account", account.Number)
+		}
 
-		if err = uc.accountStorage.Save(ctx, &account); err != nil {
-			log.Error("save account", "error", err)
+		payments := uc.refreshPayments(ctx, log, user.Session, account)
+
+		refresh.Account = account
+		if err = uc.transactor.InTransaction(ctx, func(ctx context.Context) error {
+			return uc.saveRefresh(ctx, &refresh)
+		}); err != nil {
+			log.Error("save account refresh", "error", err, "account", account.Number)
 			continue
 		}
+
+		uc.publishRefresh(ctx, refresh, payments)
+	}
+
+	return nil
+}
+
+// dropSession forgets the expired session of the user, so the next refresh logs in again even if this one fails to.
+// It returns megaline.ErrSessionExpired unless the user can't be saved.
+func (uc *BalanceUseCase) dropSession(ctx context.Context, log *slog.Logger, user *model.User) error {
+	user.Session = ""
+	if err := uc.userStorage.Save(ctx, user); err != nil {
+		log.Error("save user", "error", err)
+		return fmt.Errorf("save user: %w", err)
+	}
+
+	return megaline.ErrSessionExpired
+}
+
+// saveRefresh saves the account with the snapshot of the refresh and enqueues the alerts about the changes. It is
+// run in a transaction, so the alerts are never lost or enqueued for the changes that are not saved.
+func (uc *BalanceUseCase) saveRefresh(ctx context.Context, refresh *AccountRefresh) error {
+	if err := uc.accountStorage.Save(ctx, &refresh.Account); err != nil {
+		return fmt.Errorf("save account: %w", err)
+	}
+
+	previous, err := uc.snapshotStorage.Latest(ctx, refresh.Account.ID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("get latest balance snapshot: %w", err)
+	}
+
+	refresh.PreviousSnapshot = previous
+	refresh.Current = model.BalanceSnapshot{
+		AccountID:    refresh.Account.ID,
+		Balance:      refresh.Account.Balance,
+		TariffAmount: refresh.Account.TariffAmount,
+		TariffName:   refresh.Account.TariffName,
+		Billing:      refresh.Account.Billing,
+	}
+
+	if err = uc.snapshotStorage.Create(ctx, &refresh.Current); err != nil {
+		return fmt.Errorf("save balance snapshot: %w", err)
+	}
+
+	if err = uc.alerts.Alert(ctx, *refresh); err != nil {
+		return fmt.Errorf("alert: %w", err)
 	}
 
 	return nil
 }
+
+// publishRefresh publishes the events of the saved refresh. The alerts are enqueued by saveRefresh already, the
+// subscribers get only what may be lost.
+func (uc *BalanceUseCase) publishRefresh(ctx context.Context, refresh AccountRefresh, payments []model.Payment) {
+	account, previous, current := refresh.Account, refresh.PreviousSnapshot, refresh.Current
+
+	for _, payment := range payments {
+		uc.events.Publish(ctx, event.PaymentDetected{UserID: refresh.UserID, Account: account, Payment: payment})
+	}
+
+	if previous == nil || previous.Balance.Cmp(current.Balance) != 0 || len(payments) > 0 {
+		uc.events.Publish(ctx, event.BalanceChanged{UserID: refresh.UserID, Account: account, Previous: previous, Current: current})
+	}
+
+	if isTariffChange(refresh.PreviousTariff, refresh.PreviousTariffName, account) {
+		uc.events.Publish(ctx, event.TariffChanged{UserID: refresh.UserID, Account: account, PreviousAmount: refresh.PreviousTariff, PreviousName: refresh.PreviousTariffName, SnapshotID: current.ID})
+	}
+
+	if refresh.PreviousStatus != "" && refresh.PreviousStatus != account.Status {
+		uc.events.Publish(ctx, event.StatusChanged{UserID: refresh.UserID, Account: account, Previous: refresh.PreviousStatus, SnapshotID: current.ID})
+	}
+
+	if !refresh.PreviousBilling.IsZero() && !refresh.PreviousBilling.Equal(account.Billing) {
+		uc.events.Publish(ctx, event.PeriodRolledOver{UserID: refresh.UserID, Account: account, Previous: refresh.PreviousBilling, PreviousTariff: refresh.PreviousTariff, PreviousSnapshot: previous, Current: current})
+	}
+}
+
+// refreshPayments stores the payments of the account listed in the personal cabinet that are not stored yet. It
+// returns the new payments, i.e. the payments made since the previous refresh.
+func (uc *BalanceUseCase) refreshPayments(ctx context.Context, log *slog.Logger, session string, account model.Account) []model.Payment {
+	log = log.With("account", account.Number)
+
+	body, err := uc.megaLine.GetPayments(ctx, session, account.Number)
+	if err != nil {
+		log.Error("get payments", "error", err)
+		return nil
+	}
+
+	records, err := megaline.ParsePayments(body, uc.location)
+	if err != nil {
+		log.Error("parse payments", "error", err)
+	}
+
+	var created []model.Payment
+	for _, record := range records {
+		payment := model.NewPayment(account.ID, record.PaidAt, record.Amount, record.Source)
+		isNew, err := uc.paymentStorage.CreateIfNew(ctx, &payment)
+		if err != nil {
+			log.Error("save payment", "error", err)
+			continue
+		}
+
+		if isNew {
+			created = append(created, payment)
+		}
+	}
+
+	return created
+}
+
+// loginFailed audits the failed login and publishes it with the reason.
+func (uc *BalanceUseCase) loginFailed(ctx context.Context, userID int64, reason string) {
+	_ = uc.auditor.Record(ctx, model.AuditActionLoginFailed, userID, reason)
+	uc.events.Publish(ctx, event.LoginFailed{UserID: userID, Reason: reason})
+}
+
+// RecentPayments returns the newest payments of the account.
+func (uc *BalanceUseCase) RecentPayments(ctx context.Context, accountID int, limit int) ([]model.Payment, error) {
+	payments, err := uc.paymentStorage.ListRecent(ctx, accountID, limit)
+	if err != nil {
+		uc.logger.Error("list payments", "method", "RecentPayments", "error", err)
+		return nil, fmt.Errorf("list payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+func refreshResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
@@ -0,0 +1,384 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/event"
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type fakeUserStorage struct {
+	userStorage
+	users []model.User
+	// sessions are the sessions of the saved users in the order of saving.
+	sessions []string
+}
+
+func (s *fakeUserStorage) ListWithAccounts(ctx context.Context) ([]model.User, error) {
+	return s.users, nil
+}
+
+func (s *fakeUserStorage) Save(ctx context.Context, user *model.User) error {
+	s.sessions = append(s.sessions, user.Session)
+	return nil
+}
+
+func (s *fakeUserStorage) GetOrCreateByTelegramID(ctx context.Context, userID int64) (*model.User, bool, error) {
+	for _, user := range s.users {
+		if user.TelegramID == userID {
+			return &user, false, nil
+		}
+	}
+
+	return nil, false, errors.New("unknown user")
+}
+
+// fakeMegaLine answers every login with the status and the first expired requests of the account detail with the
+// login page.
+type fakeMegaLine struct {
+	megaLine
+	status  megaline.LoginStatus
+	logins  int
+	expired int
+}
+
+func (m *fakeMegaLine) Login(ctx context.Context, username, password string) (*megaline.LoginResult, error) {
+	m.logins++
+
+	result := &megaline.LoginResult{Status: m.status, Session: "session"}
+	if m.status == megaline.LoginStatusCaptcha {
+		result.Captcha = &megaline.Captcha{Field: "code", Image: []byte("png")}
+	}
+
+	return result, nil
+}
+
+func (m *fakeMegaLine) GetAccountsDetail(ctx context.Context, session, account string) ([]byte, error) {
+	if m.expired > 0 {
+		m.expired--
+		return nil, megaline.ErrSessionExpired
+	}
+
+	return []byte("<html></html>"), nil
+}
+
+func (m *fakeMegaLine) GetPayments(ctx context.Context, session, account string) ([]byte, error) {
+	return []byte("<html></html>"), nil
+}
+
+// fakeTransactor runs the function in a pretend transaction, active while the function runs.
+type fakeTransactor struct {
+	active bool
+}
+
+func (tx *fakeTransactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx.active = true
+	defer func() { tx.active = false }()
+
+	return fn(ctx)
+}
+
+type fakeAccountStorage struct {
+	accountStorage
+	saved   int
+	deleted []int
+}
+
+func (s *fakeAccountStorage) Save(ctx context.Context, account *model.Account) error {
+	s.saved++
+	return nil
+}
+
+func (s *fakeAccountStorage) DeleteByIDs(ctx context.Context, accountIDs []int) error {
+	s.deleted = append(s.deleted, accountIDs...)
+	return nil
+}
+
+type fakeSnapshotStorage struct {
+	snapshots []model.BalanceSnapshot
+}
+
+func (s *fakeSnapshotStorage) Latest(ctx context.Context, accountID int) (*model.BalanceSnapshot, error) {
+	if len(s.snapshots) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	latest := s.snapshots[len(s.snapshots)-1]
+	return &latest, nil
+}
+
+func (s *fakeSnapshotStorage) Create(ctx context.Context, snapshot *model.BalanceSnapshot) error {
+	snapshot.ID = len(s.snapshots) + 1
+	snapshot.CreatedAt = time.Now()
+	s.snapshots = append(s.snapshots, *snapshot)
+	return nil
+}
+
+// fakeAlerter records the refreshes it was asked to alert about and whether they were in the transaction.
+type fakeAlerter struct {
+	tx        *fakeTransactor
+	err       error
+	refreshes []AccountRefresh
+	inTx      []bool
+}
+
+func (a *fakeAlerter) Alert(ctx context.Context, refresh AccountRefresh) error {
+	a.refreshes = append(a.refreshes, refresh)
+	a.inTx = append(a.inTx, a.tx.active)
+	return a.err
+}
+
+type fakeLoginGuard struct {
+	failures int
+}
+
+func (g *fakeLoginGuard) Check(ctx context.Context, userID int64, login string) error   { return nil }
+func (g *fakeLoginGuard) Succeed(ctx context.Context, userID int64, login string) error { return nil }
+
+func (g *fakeLoginGuard) Fail(ctx context.Context, userID int64, login string) error {
+	g.failures++
+	return nil
+}
+
+type fakeAuditor struct{}
+
+func (fakeAuditor) Record(ctx context.Context, action string, userID int64, details string) error {
+	return nil
+}
+
+type fakePublisher struct {
+	events []event.Event
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event event.Event) {
+	p.events = append(p.events, event)
+}
+
+func newTestBalanceUseCase(status megaline.LoginStatus) (*BalanceUseCase, *fakeMegaLine, *fakeLoginGuard) {
+	users := &fakeUserStorage{users: []model.User{
+		{ID: 1, TelegramID: 100, AuthUsername: "0555000001", AuthPassword: "secret"},
+	}}
+
+	megaLine := &fakeMegaLine{status: status}
+	guard := &fakeLoginGuard{}
+	uc := NewBalanceUseCase(discardLogger, &fakeTransactor{}, users, nil, nil, nil, megaLine, guard, fakeAuditor{}, time.UTC, nil, &fakePublisher{})
+
+	return uc, megaLine, guard
+}
+
+func TestRefreshAllCaptcha(t *testing.T) {
+	uc, megaLine, _ := newTestBalanceUseCase(megaline.LoginStatusCaptcha)
+	ctx := context.Background()
+
+	uc.refreshAll(ctx)
+	if megaLine.logins != 1 {
+		t.Fatalf("logins = %d, want 1", megaLine.logins)
+	}
+
+	if _, ok := uc.takePendingCaptcha(100); ok {
+		t.Error("the captcha of the background login is kept, want nobody to answer it")
+	}
+
+	uc.refreshAll(ctx)
+	if megaLine.logins != 1 {
+		t.Errorf("logins after the captcha = %d, want the user skipped", megaLine.logins)
+	}
+
+	// The user answering the captcha themselves clears the mark
+	var captchaErr *CaptchaRequiredError
+	if err := uc.UpdateBalance(ctx, 100); !errors.As(err, &captchaErr) {
+		t.Fatalf("UpdateBalance() error = %v, want CaptchaRequiredError", err)
+	}
+
+	if !uc.awaitsCaptcha(100) {
+		t.Error("awaitsCaptcha() = false while the user answers the captcha")
+	}
+
+	uc.refreshAll(ctx)
+	if megaLine.logins != 2 {
+		t.Errorf("logins = %d, want the background refresh not to replace the session of the answered captcha", megaLine.logins)
+	}
+}
+
+func TestRefreshAllBadCredentials(t *testing.T) {
+	uc, megaLine, guard := newTestBalanceUseCase(megaline.LoginStatusBadCredentials)
+	ctx := context.Background()
+
+	uc.refreshAll(ctx)
+	uc.refreshAll(ctx)
+
+	if megaLine.logins != 2 {
+		t.Errorf("logins = %d, want 2", megaLine.logins)
+	}
+
+	if guard.failures != 0 {
+		t.Errorf("failures counted by the background refresh = %d, want 0", guard.failures)
+	}
+
+	if err := uc.UpdateBalance(ctx, 100); !errors.Is(err, ErrBadCredentials) {
+		t.Fatalf("UpdateBalance() error = %v, want ErrBadCredentials", err)
+	}
+
+	if guard.failures != 1 {
+		t.Errorf("failures counted by the interactive refresh = %d, want 1", guard.failures)
+	}
+}
+
+func TestRefreshAccountsAlertsInTransaction(t *testing.T) {
+	tests := []struct {
+		name       string
+		alertErr   error
+		wantEvents bool
+	}{
+		{name: "alerts enqueued", wantEvents: true},
+		{name: "failed alert", alertErr: errors.New("database is locked")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTransactor{}
+			alerts := &fakeAlerter{tx: tx, err: tt.alertErr}
+			events := &fakePublisher{}
+			snapshots := &fakeSnapshotStorage{snapshots: []model.BalanceSnapshot{{ID: 1, AccountID: 1, Balance: model.Som(500)}}}
+
+			uc := NewBalanceUseCase(discardLogger, tx, &fakeUserStorage{}, &fakeAccountStorage{}, snapshots, nil, &fakeMegaLine{}, &fakeLoginGuard{}, fakeAuditor{}, time.UTC, alerts, events)
+
+			user := &model.User{TelegramID: 100, Session: "session", Accounts: []model.Account{
+				{ID: 1, Number: "996555000001", Balance: model.Som(400), Status: model.AccountStatusActive},
+			}}
+
+			if err := uc.refreshAccounts(context.Background(), discardLogger, user); err != nil {
+				t.Fatalf("refreshAccounts() error = %v", err)
+			}
+
+			if len(alerts.refreshes) != 1 || !alerts.inTx[0] {
+				t.Fatalf("alerted %d refreshes in transaction %v, want one in the transaction", len(alerts.refreshes), alerts.inTx)
+			}
+
+			refresh := alerts.refreshes[0]
+			if refresh.PreviousSnapshot == nil || refresh.PreviousSnapshot.ID != 1 || refresh.Current.ID != 2 {
+				t.Errorf("refresh snapshots = %+v, %+v, want the latest and the new one", refresh.PreviousSnapshot, refresh.Current)
+			}
+
+			if refresh.UserID != 100 || refresh.PreviousStatus != model.AccountStatusActive {
+				t.Errorf("refresh = %+v, want the user and the previous status", refresh)
+			}
+
+			if got := len(events.events) > 0; got != tt.wantEvents {
+				t.Errorf("published %d events, want events %v", len(events.events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestApplyLoginReconcilesAccounts(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantNumbers []string
+		wantDeleted []int
+	}{
+		{
+			name:        "accounts of the replaced credentials",
+			body:        `<select class="account_selector"><option>996555000002</option><option>996555000003</option></select>`,
+			wantNumbers: []string{"996555000002", "996555000003"},
+			wantDeleted: []int{1},
+		},
+		{
+			name:        "no accounts listed",
+			body:        `<html></html>`,
+			wantNumbers: []string{"996555000001", "996555000002"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := &fakeAccountStorage{}
+			uc := NewBalanceUseCase(discardLogger, &fakeTransactor{}, &fakeUserStorage{}, accounts, nil, nil, &fakeMegaLine{}, &fakeLoginGuard{}, fakeAuditor{}, time.UTC, nil, &fakePublisher{})
+
+			user := &model.User{ID: 1, TelegramID: 100, Accounts: []model.Account{
+				{ID: 1, UserID: 1, Number: "996555000001"},
+				{ID: 2, UserID: 1, Number: "996555000002"},
+			}}
+
+			result := &megaline.LoginResult{Status: megaline.LoginStatusSuccess, Session: "session", Body: []byte(tt.body)}
+			if err := uc.applyLogin(context.Background(), discardLogger, user, result, true); err != nil {
+				t.Fatalf("applyLogin() error = %v", err)
+			}
+
+			var numbers []string
+			for _, account := range user.Accounts {
+				numbers = append(numbers, account.Number)
+			}
+
+			if !slices.Equal(numbers, tt.wantNumbers) {
+				t.Errorf("accounts = %v, want %v", numbers, tt.wantNumbers)
+			}
+
+			if !slices.Equal(accounts.deleted, tt.wantDeleted) {
+				t.Errorf("deleted accounts = %v, want %v", accounts.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestUpdateBalanceSessionExpired(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        megaline.LoginStatus
+		expired       int
+		wantErr       error
+		wantSnapshots int
+		wantSession   string
+	}{
+		{name: "login repeated", status: megaline.LoginStatusSuccess, expired: 1, wantSnapshots: 1, wantSession: "session"},
+		{name: "repeated login requires captcha", status: megaline.LoginStatusCaptcha, expired: 1},
+		{name: "expired after the repeated login", status: megaline.LoginStatusSuccess, expired: 2, wantErr: megaline.ErrSessionExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTransactor{}
+			users := &fakeUserStorage{users: []model.User{{
+				ID: 1, TelegramID: 100, AuthUsername: "0555000001", AuthPassword: "secret", Session: "stale",
+				Accounts: []model.Account{{ID: 1, UserID: 1, Number: "996555000001", Status: model.AccountStatusActive}},
+			}}}
+			megaLine := &fakeMegaLine{status: tt.status, expired: tt.expired}
+			snapshots := &fakeSnapshotStorage{}
+
+			uc := NewBalanceUseCase(discardLogger, tx, users, &fakeAccountStorage{}, snapshots, nil, megaLine, &fakeLoginGuard{}, fakeAuditor{}, time.UTC, &fakeAlerter{tx: tx}, &fakePublisher{})
+
+			err := uc.UpdateBalance(context.Background(), 100)
+			if tt.status == megaline.LoginStatusCaptcha {
+				var captchaErr *CaptchaRequiredError
+				if !errors.As(err, &captchaErr) {
+					t.Fatalf("UpdateBalance() error = %v, want CaptchaRequiredError", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateBalance() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if megaLine.logins != 1 {
+				t.Errorf("logins = %d, want 1", megaLine.logins)
+			}
+
+			if len(snapshots.snapshots) != tt.wantSnapshots {
+				t.Errorf("snapshots = %d, want %d", len(snapshots.snapshots), tt.wantSnapshots)
+			}
+
+			if len(users.sessions) == 0 || users.sessions[len(users.sessions)-1] != tt.wantSession {
+				t.Errorf("saved sessions = %q, want the last one %q", users.sessions, tt.wantSession)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const calendarTokenBytes = 24
+
+// ErrCalendarFeedDisabled is returned when the calendar feed is asked for but the HTTP endpoint is not configured.
+var ErrCalendarFeedDisabled = errors.New("calendar feed is disabled")
+
+type calendarUserStorage interface {
+	GetByTelegramID(ctx context.Context, userID int64) (*model.User, error)
+	GetByCalendarTokenHash(ctx context.Context, tokenHash string) (*model.User, error)
+	SetCalendarTokenHash(ctx context.Context, userID int64, tokenHash *string) error
+}
+
+// CalendarUseCase turns the billing periods of the accounts into the calendar events of the due dates. The same
+// calendar is sent as a document and served by the feed the calendar apps subscribe to with a secret token.
+type CalendarUseCase struct {
+	logger      *slog.Logger
+	salt        string
+	location    *time.Location
+	feedURL     string
+	userStorage calendarUserStorage
+	auditor     auditor
+}
+
+func NewCalendarUseCase(logger *slog.Logger, salt string, location *time.Location, feedURL string, userStorage calendarUserStorage, auditor auditor) *CalendarUseCase {
+	return &CalendarUseCase{
+		logger:      logger.With("use_case", "CalendarUseCase"),
+		salt:        salt,
+		location:    location,
+		feedURL:     strings.TrimSuffix(feedURL, "/"),
+		userStorage: userStorage,
+		auditor:     auditor,
+	}
+}
+
+// Calendar returns the ICS calendar of the user.
+func (uc *CalendarUseCase) Calendar(ctx context.Context, userID int64) ([]byte, error) {
+	user, err := uc.userStorage.GetByTelegramID(ctx, userID)
+	if err != nil {
+		uc.logger.Error("get user by telegram ID", "method", "Calendar", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("get user by telegram ID: %w", err)
+	}
+
+	return buildCalendar(user.Accounts, uc.location, time.Now()), nil
+}
+
+// CalendarByToken returns the ICS calendar of the user the feed token was issued to.
+func (uc *CalendarUseCase) CalendarByToken(ctx context.Context, token string) ([]byte, error) {
+	user, err := uc.userStorage.GetByCalendarTokenHash(ctx, keyedHash(uc.salt, token))
+	if err != nil {
+		return nil, fmt.Errorf("get user by calendar token: %w", err)
+	}
+
+	return buildCalendar(user.Accounts, uc.location, time.Now()), nil
+}
+
+// IssueFeedURL issues a new feed token to the user and returns the URL of the feed. The previous token stops
+// working, only the hash of the token is stored.
+func (uc *CalendarUseCase) IssueFeedURL(ctx context.Context, userID int64) (string, error) {
+	if uc.feedURL == "" {
+		return "", ErrCalendarFeedDisabled
+	}
+
+	secret := make([]byte, calendarTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+
+	token := hex.EncodeToString(secret)
+	tokenHash := keyedHash(uc.salt, token)
+	if err := uc.userStorage.SetCalendarTokenHash(ctx, userID, &tokenHash); err != nil {
+		uc.logger.Error("set calendar token", "method", "IssueFeedURL", "user_id", userID, "error", err)
+		return "", fmt.Errorf("set calendar token: %w", err)
+	}
+
+	_ = uc.auditor.Record(ctx, model.AuditActionCalendarTokenIssued, userID, "")
+	return uc.feedURL + "/calendar/" + token + ".ics", nil
+}
+
+// RevokeFeed stops the feed of the user.
+func (uc *CalendarUseCase) RevokeFeed(ctx context.Context, userID int64) error {
+	if err := uc.userStorage.SetCalendarTokenHash(ctx, userID, nil); err != nil {
+		uc.logger.Error("reset calendar token", "method", "RevokeFeed", "user_id", userID, "error", err)
+		return fmt.Errorf("reset calendar token: %w", err)
+	}
+
+	_ = uc.auditor.Record(ctx, model.AuditActionCalendarTokenRevoked, userID, "")
+	return nil
+}
+
+// buildCalendar returns the calendar with an all-day event on the last day of the billing period of every account.
+// The event of the account keeps its UID across the periods, so that the imported event moves to the new due date
+// when the period rolls over instead of piling up.
+func buildCalendar(accounts []model.Account, loc *time.Location, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeFoldedLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//megalinekg_bot//MegaLine billing//RU")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "MegaLine")
+	line("X-WR-TIMEZONE", loc.String())
+
+	for _, account := range accounts {
+		billing := account.Billing.In(loc)
+		if billing.IsZero() {
+			continue
+		}
+
+		dueDate := billing.To
+		summary := "Оплата MegaLine " + account.Number
+		if due := account.AmountDue(); !due.IsZero() {
+			summary += ": " + due.String()
+		}
+
+		description := fmt.Sprintf("К оплате: %s\nБаланс: %s\nТариф: %s", account.AmountDue(), account.Balance, account.TariffAmount)
+
+		line("BEGIN", "VEVENT")
+		line("UID", "account-"+escapeICS(account.Number)+"@megalinekg-bot")
+		line("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+		line("SEQUENCE", fmt.Sprint(dueDate.Unix()/int64(24*time.Hour/time.Second)))
+		line("DTSTART;VALUE=DATE", dueDate.Format("20060102"))
+		line("DTEND;VALUE=DATE", dueDate.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", escapeICS(summary))
+		line("DESCRIPTION", escapeICS(description))
+		line("TRANSP", "TRANSPARENT")
+		line("BEGIN", "VALARM")
+		line("ACTION", "DISPLAY")
+		line("TRIGGER", "-P1D")
+		line("DESCRIPTION", escapeICS("Оплата MegaLine "+account.Number))
+		line("END", "VALARM")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// escapeICS escapes the text value as RFC 5545 requires.
+func escapeICS(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// writeFoldedLine writes the content line folded at 75 octets without splitting the UTF-8 characters.
+func writeFoldedLine(buf *bytes.Buffer, line string) {
+	const maxOctets = 75
+
+	for width := maxOctets; len(line) > width; width = maxOctets - 1 {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+
+	buf.WriteString(line + "\r\n")
+}
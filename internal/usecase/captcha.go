@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+)
+
+// captchaTTL is how long the answer to the captcha is awaited, MegaLine drops the session afterward anyway.
+const captchaTTL = 10 * time.Minute
+
+var (
+	ErrBadCredentials      = errors.New("bad credentials")
+	ErrMegaLineBlocked     = errors.New("login blocked by MegaLine")
+	ErrMegaLineMaintenance = errors.New("MegaLine is under maintenance")
+	ErrNoPendingCaptcha    = errors.New("no pending captcha")
+)
+
+// CaptchaRequiredError is returned when MegaLine asks for a captcha on login. The image is to be shown to the user
+// and the answer passed to SolveCaptcha.
+type CaptchaRequiredError struct {
+	Image []byte
+}
+
+func (e *CaptchaRequiredError) Error() string {
+	return "captcha required"
+}
+
+type pendingCaptcha struct {
+	session   string
+	captcha   *megaline.Captcha
+	expiresAt time.Time
+}
+
+func (uc *BalanceUseCase) storePendingCaptcha(userID int64, session string, captcha *megaline.Captcha) {
+	uc.pendingCaptchasMu.Lock()
+	defer uc.pendingCaptchasMu.Unlock()
+
+	// The captchas the users never answered are dropped here, so that they don't pile up
+	now := time.Now()
+	for id, pending := range uc.pendingCaptchas {
+		if now.After(pending.expiresAt) {
+			delete(uc.pendingCaptchas, id)
+		}
+	}
+
+	uc.pendingCaptchas[userID] = pendingCaptcha{session: session, captcha: captcha, expiresAt: now.Add(captchaTTL)}
+}
+
+func (uc *BalanceUseCase) takePendingCaptcha(userID int64) (pendingCaptcha, bool) {
+	uc.pendingCaptchasMu.Lock()
+	defer uc.pendingCaptchasMu.Unlock()
+
+	pending, ok := uc.pendingCaptchas[userID]
+	delete(uc.pendingCaptchas, userID)
+
+	if !ok || time.Now().After(pending.expiresAt) {
+		return pendingCaptcha{}, false
+	}
+
+	return pending, true
+}
+
+// markCaptchaRequired records that the background login of the user hit a captcha, the background refresh skips
+// the user until they log in themselves.
+func (uc *BalanceUseCase) markCaptchaRequired(userID int64) {
+	uc.pendingCaptchasMu.Lock()
+	defer uc.pendingCaptchasMu.Unlock()
+
+	uc.captchaRequired[userID] = struct{}{}
+}
+
+func (uc *BalanceUseCase) clearCaptchaRequired(userID int64) {
+	uc.pendingCaptchasMu.Lock()
+	defer uc.pendingCaptchasMu.Unlock()
+
+	delete(uc.captchaRequired, userID)
+}
+
+// awaitsCaptcha reports whether the login of the user needs a captcha: the background login hit one, or the user
+// is answering one, which the new login of the background refresh would invalidate.
+func (uc *BalanceUseCase) awaitsCaptcha(userID int64) bool {
+	uc.pendingCaptchasMu.Lock()
+	defer uc.pendingCaptchasMu.Unlock()
+
+	if _, ok := uc.captchaRequired[userID]; ok {
+		return true
+	}
+
+	pending, ok := uc.pendingCaptchas[userID]
+	return ok && time.Now().Before(pending.expiresAt)
+}
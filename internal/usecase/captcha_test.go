@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+)
+
+func TestPendingCaptchas(t *testing.T) {
+	uc := &BalanceUseCase{pendingCaptchas: map[int64]pendingCaptcha{
+		1: {session: "abandoned", expiresAt: time.Now().Add(-time.Minute)},
+		2: {session: "awaited", expiresAt: time.Now().Add(time.Minute)},
+	}}
+
+	uc.storePendingCaptcha(3, "new", &megaline.Captcha{Field: "code"})
+
+	if _, ok := uc.pendingCaptchas[1]; ok {
+		t.Error("the expired captcha is kept, want it pruned")
+	}
+
+	if len(uc.pendingCaptchas) != 2 {
+		t.Errorf("pending captchas = %d, want 2", len(uc.pendingCaptchas))
+	}
+
+	if pending, ok := uc.takePendingCaptcha(3); !ok || pending.session != "new" {
+		t.Errorf("takePendingCaptcha() = %+v, %v, want the stored captcha", pending, ok)
+	}
+
+	if _, ok := uc.takePendingCaptcha(3); ok {
+		t.Error("takePendingCaptcha() returned the captcha twice")
+	}
+}
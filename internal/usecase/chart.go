@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/chart"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type historySnapshotStorage interface {
+	ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.BalanceSnapshot, error)
+}
+
+type historyPaymentStorage interface {
+	ListByAccountSince(ctx context.Context, accountID int, since time.Time) ([]model.Payment, error)
+}
+
+// ChartUseCase draws the history of the accounts.
+type ChartUseCase struct {
+	logger          *slog.Logger
+	location        *time.Location
+	snapshotStorage historySnapshotStorage
+	paymentStorage  historyPaymentStorage
+}
+
+func NewChartUseCase(logger *slog.Logger, location *time.Location, snapshotStorage historySnapshotStorage, paymentStorage historyPaymentStorage) *ChartUseCase {
+	return &ChartUseCase{
+		logger:          logger.With("use_case", "ChartUseCase"),
+		location:        location,
+		snapshotStorage: snapshotStorage,
+		paymentStorage:  paymentStorage,
+	}
+}
+
+// BalanceChart returns the PNG chart of the balance of the account since the moment, the zero moment charts the
+// whole history. chart.ErrNoData is returned when there are no snapshots to draw.
+func (uc *ChartUseCase) BalanceChart(ctx context.Context, account model.Account, since time.Time) ([]byte, error) {
+	log := uc.logger.With("method", "BalanceChart", "account", account.Number)
+
+	snapshots, err := uc.snapshotStorage.ListByAccountSince(ctx, account.ID, since)
+	if err != nil {
+		log.Error("list balance snapshots", "error", err)
+		return nil, fmt.Errorf("list balance snapshots: %w", err)
+	}
+
+	payments, err := uc.paymentStorage.ListByAccountSince(ctx, account.ID, since)
+	if err != nil {
+		log.Error("list payments", "error", err)
+		return nil, fmt.Errorf("list payments: %w", err)
+	}
+
+	balanceChart := chart.BalanceChart{
+		Title:    fmt.Sprintf("Account %s, balance in %s", account.Number, model.CurrencyKGS),
+		Location: uc.location,
+	}
+
+	var periodStart time.Time
+	for _, snapshot := range snapshots {
+		balanceChart.Balance = append(balanceChart.Balance, chart.Point{
+			Time:  snapshot.CreatedAt,
+			Value: float64(snapshot.Balance.Tyiyn) / 100,
+		})
+
+		// The first period seen is not a boundary, it has started before the history
+		if from := snapshot.Billing.From; !from.IsZero() && !from.Equal(periodStart) {
+			if !periodStart.IsZero() {
+				balanceChart.Boundaries = append(balanceChart.Boundaries, from)
+			}
+
+			periodStart = from
+		}
+	}
+
+	for _, payment := range payments {
+		balanceChart.Payments = append(balanceChart.Payments, payment.PaidAt)
+	}
+
+	image, err := chart.Render(balanceChart)
+	if err != nil {
+		return nil, fmt.Errorf("render chart: %w", err)
+	}
+
+	return image, nil
+}
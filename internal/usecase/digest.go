@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// PeriodDigest sums up the billing period that has just ended for the account.
+type PeriodDigest struct {
+	// Account is the account in the new period, with the current balance and the next due date.
+	Account AccountSummary
+	// Period is the period that has ended.
+	Period model.BillingPeriod
+	// Charged is how much the balance dropped across the rollover, the payments taken into account. It is derived
+	// from the snapshots before and after the rollover when they are one period apart, ChargeObserved is false
+	// otherwise.
+	Charged        model.Money
+	ChargeObserved bool
+	// Expected is the tariff of the period that has ended, the charge the rollover is expected to make.
+	Expected model.Money
+	// Payments are the payments made during the period that has ended.
+	Payments []model.Payment
+	// Paid is the sum of the payments.
+	Paid model.Money
+}
+
+// SetDigest turns the digest of the billing period of the account of the user on or off.
+func (uc *BalanceUseCase) SetDigest(ctx context.Context, userID int64, accountNumber string, enabled bool) error {
+	log := uc.logger.With("method", "SetDigest", "user_id", userID)
+
+	account, err := uc.accountOf(ctx, log, userID, accountNumber)
+	if err != nil {
+		return err
+	}
+
+	if err = uc.accountStorage.SetDigestEnabled(ctx, account.ID, enabled); err != nil {
+		log.Error("set digest enabled", "error", err)
+		return fmt.Errorf("set digest enabled: %w", err)
+	}
+
+	return nil
+}
+
+// alertPeriodDigest sends the digest of the period that has ended to the owner of the account who opted in to it.
+func (uc *AlertUseCase) alertPeriodDigest(ctx context.Context, refresh AccountRefresh) error {
+	if refresh.PreviousBilling.IsZero() || refresh.PreviousBilling.Equal(refresh.Account.Billing) {
+		return nil
+	}
+
+	uc.logger.Info("billing period rolled over", "method", "alertPeriodDigest", "user_id", refresh.UserID, "account", refresh.Account.Number,
+		"previous_to", refresh.PreviousBilling.To, "billing_to", refresh.Account.Billing.To)
+
+	if !refresh.Account.DigestEnabled {
+		return nil
+	}
+
+	digest, err := uc.periodDigest(ctx, refresh)
+	if err != nil {
+		return fmt.Errorf("build period digest: %w", err)
+	}
+
+	key := fmt.Sprintf("%s:%d:%s", model.NotificationKindPeriodDigest, refresh.Account.ID, digest.Period.To.Format(time.DateOnly))
+	if err = uc.outbox.Enqueue(ctx, refresh.UserID, model.NotificationKindPeriodDigest, key, digest); err != nil {
+		return fmt.Errorf("enqueue period digest: %w", err)
+	}
+
+	return nil
+}
+
+// periodDigest builds the digest of the period that has ended. The charge is observed only when the snapshots
+// around the rollover are one period apart, otherwise it is mixed with the charges of the other periods.
+func (uc *AlertUseCase) periodDigest(ctx context.Context, refresh AccountRefresh) (PeriodDigest, error) {
+	period := refresh.PreviousBilling.In(uc.location)
+
+	payments, err := uc.paymentStorage.ListByAccountSince(ctx, refresh.Account.ID, period.From)
+	if err != nil {
+		return PeriodDigest{}, fmt.Errorf("list payments: %w", err)
+	}
+
+	digest := PeriodDigest{Account: summarizeAccount(refresh.Account), Period: period, Expected: refresh.PreviousTariff}
+	if refresh.PreviousSnapshot != nil && rolloversBetween(refresh.PreviousSnapshot.Billing, refresh.Current.Billing) == 1 {
+		if digest.Charged, err = uc.chargedBetween(ctx, *refresh.PreviousSnapshot, refresh.Current); err != nil {
+			return PeriodDigest{}, err
+		}
+
+		digest.ChargeObserved = true
+	}
+
+	for _, payment := range payments {
+		if !period.Contains(payment.PaidAt) {
+			continue
+		}
+
+		digest.Payments = append(digest.Payments, payment)
+		digest.Paid = digest.Paid.Add(payment.Amount)
+	}
+
+	return digest, nil
+}
+
+// chargedBetween returns how much the balance dropped between the snapshots, the stored payments made between them
+// are added back.
+func (uc *AlertUseCase) chargedBetween(ctx context.Context, previous, current model.BalanceSnapshot) (model.Money, error) {
+	paid, err := uc.paidBetween(ctx, previous, current)
+	if err != nil {
+		return model.Money{}, err
+	}
+
+	return previous.Balance.Add(paid).Sub(current.Balance), nil
+}
@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type fakePaymentStorage struct {
+	payments []model.Payment
+}
+
+func (s *fakePaymentStorage) ListByAccountSince(_ context.Context, accountID int, since time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	for _, payment := range s.payments {
+		if payment.AccountID == accountID && !payment.PaidAt.Before(since) {
+			payments = append(payments, payment)
+		}
+	}
+
+	return payments, nil
+}
+
+func (s *fakePaymentStorage) ListByAccountBetween(_ context.Context, accountID int, after, until time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	for _, payment := range s.payments {
+		if payment.AccountID == accountID && payment.PaidAt.After(after) && !payment.PaidAt.After(until) {
+			payments = append(payments, payment)
+		}
+	}
+
+	return payments, nil
+}
+
+func utcDay(month time.Month, day int) time.Time {
+	return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func snapshotAt(createdAt time.Time, balance model.Money, billing model.BillingPeriod) *model.BalanceSnapshot {
+	return &model.BalanceSnapshot{AccountID: 1, Balance: balance, TariffAmount: model.Som(950), Billing: billing, CreatedAt: createdAt}
+}
+
+func TestPeriodDigest(t *testing.T) {
+	october := model.BillingPeriod{From: utcDay(10, 1), To: utcDay(10, 31)}
+	november := october.Next()
+	december := november.Next()
+
+	payments := &fakePaymentStorage{payments: []model.Payment{
+		model.NewPayment(1, utcDay(9, 30), model.Som(100), "Терминал"),
+		model.NewPayment(1, utcDay(10, 10), model.Som(500), "Терминал"),
+		model.NewPayment(1, utcDay(10, 31).Add(20*time.Hour), model.Som(300), "Элсом"),
+		model.NewPayment(2, utcDay(10, 31).Add(21*time.Hour), model.Som(700), "Элсом"),
+	}}
+
+	tests := []struct {
+		name          string
+		previous      *model.BalanceSnapshot
+		current       *model.BalanceSnapshot
+		wantCharged   model.Money
+		wantObserved  bool
+		wantPaid      model.Money
+		wantPaymentsN int
+	}{
+		{
+			name:          "snapshots around the rollover with a payment between them",
+			previous:      snapshotAt(utcDay(10, 31).Add(18*time.Hour), model.Som(1000), october),
+			current:       snapshotAt(utcDay(11, 1).Add(6*time.Hour), model.Som(350), november),
+			wantCharged:   model.Som(950),
+			wantObserved:  true,
+			wantPaid:      model.Som(800),
+			wantPaymentsN: 2,
+		},
+		{
+			name:          "first snapshot of the account",
+			current:       snapshotAt(utcDay(11, 1).Add(6*time.Hour), model.Som(350), november),
+			wantPaid:      model.Som(800),
+			wantPaymentsN: 2,
+		},
+		{
+			name:          "snapshots more than one period apart",
+			previous:      snapshotAt(utcDay(10, 20), model.Som(2000), october),
+			current:       snapshotAt(utcDay(12, 2), model.Som(100), december),
+			wantPaid:      model.Som(800),
+			wantPaymentsN: 2,
+		},
+	}
+
+	uc := NewAlertUseCase(discardLogger, time.UTC, payments, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresh := AccountRefresh{
+				UserID:           100,
+				Account:          model.Account{ID: 1, Billing: tt.current.Billing},
+				PreviousSnapshot: tt.previous,
+				Current:          *tt.current,
+				PreviousBilling:  october,
+				PreviousTariff:   model.Som(950),
+			}
+
+			digest, err := uc.periodDigest(context.Background(), refresh)
+			if err != nil {
+				t.Fatalf("periodDigest() error = %v", err)
+			}
+
+			if digest.ChargeObserved != tt.wantObserved || digest.Charged != tt.wantCharged {
+				t.Errorf("charged = %v, %v, want %v, %v", digest.Charged, digest.ChargeObserved, tt.wantCharged, tt.wantObserved)
+			}
+
+			if digest.Expected != model.Som(950) {
+				t.Errorf("expected = %v, want %v", digest.Expected, model.Som(950))
+			}
+
+			if digest.Paid != tt.wantPaid || len(digest.Payments) != tt.wantPaymentsN {
+				t.Errorf("paid = %v in %d payments, want %v in %d", digest.Paid, len(digest.Payments), tt.wantPaid, tt.wantPaymentsN)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// maxForecastPeriods bounds the forecast of a tiny tariff, ten years of monthly periods is more than enough.
+const maxForecastPeriods = 120
+
+// Forecast is the outlook of the account at its current balance and tariff. MegaLine charges the tariff at the
+// start of every period, so the current period is paid already and the balance pays for the following ones.
+type Forecast struct {
+	// PeriodsCovered is the number of the following periods the balance pays for.
+	PeriodsCovered int
+	// PaidUntil is the last day of the last paid period.
+	PaidUntil time.Time
+	// RunsOutOn is the first day of the first period the balance does not pay for.
+	RunsOutOn time.Time
+	// Remainder is what is left of the balance after paying for the covered periods.
+	Remainder model.Money
+	// TopUp is the amount to pay for one more period.
+	TopUp model.Money
+}
+
+// ForecastUseCase forecasts the balance of the accounts from the tariff, the billing period and the balance.
+type ForecastUseCase struct {
+	location *time.Location
+}
+
+func NewForecastUseCase(location *time.Location) *ForecastUseCase {
+	return &ForecastUseCase{location: location}
+}
+
+// Forecast returns the forecast of the account, false if the account has no billing period or tariff to forecast
+// from.
+func (uc *ForecastUseCase) Forecast(account model.Account) (Forecast, bool) {
+	if account.Billing.IsZero() || account.TariffAmount.Cmp(model.Money{}) <= 0 {
+		return Forecast{}, false
+	}
+
+	period, balance := account.Billing.In(uc.location), account.Balance
+	covered := 0
+	for covered < maxForecastPeriods && balance.Cmp(account.TariffAmount) >= 0 {
+		balance = balance.Sub(account.TariffAmount)
+		period = period.Next()
+		covered++
+	}
+
+	return Forecast{
+		PeriodsCovered: covered,
+		PaidUntil:      period.To,
+		RunsOutOn:      period.To.AddDate(0, 0, 1),
+		Remainder:      balance,
+		TopUp:          account.TariffAmount.Sub(balance),
+	}, true
+}
+
+// TopUpUntil returns the amount to pay for the service to last until the end of the day, i.e. for every period
+// starting by then. It is zero if the balance is enough.
+func (uc *ForecastUseCase) TopUpUntil(account model.Account, day time.Time) model.Money {
+	if account.Billing.IsZero() || account.TariffAmount.Cmp(model.Money{}) <= 0 {
+		return model.Money{}
+	}
+
+	needed := model.Money{}
+	for i, period := 0, account.Billing.In(uc.location).Next(); i < maxForecastPeriods && period.From.Before(day.AddDate(0, 0, 1)); i++ {
+		needed = needed.Add(account.TariffAmount)
+		period = period.Next()
+	}
+
+	if topUp := needed.Sub(account.Balance); topUp.Cmp(model.Money{}) > 0 {
+		return topUp
+	}
+
+	return model.Money{}
+}
@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+	"github.com/aastashov/megalinekg_bot/internal/storage"
+)
+
+// LoginLockedError is returned when the MegaLine login is blocked after too many failed attempts.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("login locked until %s", e.Until.Format(time.RFC3339))
+}
+
+type loginAttemptStorage interface {
+	Get(ctx context.Context, subject string) (*model.LoginAttempt, error)
+	Save(ctx context.Context, attempt *model.LoginAttempt) error
+	Delete(ctx context.Context, subjects ...string) (int64, error)
+}
+
+// LoginLimits are the limits of the failed MegaLine logins. A subject, the Telegram user or the MegaLine login,
+// is locked out for Lockout after MaxAttempts failures within Window.
+type LoginLimits struct {
+	MaxAttemptsPerUser  int
+	MaxAttemptsPerLogin int
+	Window              time.Duration
+	Lockout             time.Duration
+}
+
+// LoginGuard stops the bot from being used as an oracle to guess MegaLine passwords. It is asked before every
+// login to MegaLine and told about the outcome afterward.
+type LoginGuard struct {
+	logger              *slog.Logger
+	salt                string
+	limits              LoginLimits
+	loginAttemptStorage loginAttemptStorage
+	auditor             auditor
+}
+
+func NewLoginGuard(logger *slog.Logger, salt string, limits LoginLimits, loginAttemptStorage loginAttemptStorage, auditor auditor) *LoginGuard {
+	return &LoginGuard{
+		logger:              logger.With("use_case", "LoginGuard"),
+		salt:                salt,
+		limits:              limits,
+		loginAttemptStorage: loginAttemptStorage,
+		auditor:             auditor,
+	}
+}
+
+// Check returns LoginLockedError if either the user or the MegaLine login is locked out.
+func (g *LoginGuard) Check(ctx context.Context, userID int64, login string) error {
+	now := time.Now()
+
+	for _, subject := range []string{g.userSubject(userID), g.loginSubject(login)} {
+		attempt, err := g.loginAttemptStorage.Get(ctx, subject)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			g.logger.Error("get login attempts", "error", err)
+			return fmt.Errorf("get login attempts: %w", err)
+		}
+
+		if attempt.IsLocked(now) {
+			return &LoginLockedError{Until: attempt.LockedUntil}
+		}
+	}
+
+	return nil
+}
+
+// Fail counts the failed login for both the user and the MegaLine login and locks them out when the limit is hit.
+func (g *LoginGuard) Fail(ctx context.Context, userID int64, login string) error {
+	log := g.logger.With("method", "Fail", "user_id", userID)
+
+	subjects := []struct {
+		subject     string
+		maxAttempts int
+		details     string
+	}{
+		{subject: g.userSubject(userID), maxAttempts: g.limits.MaxAttemptsPerUser, details: "telegram user"},
+		{subject: g.loginSubject(login), maxAttempts: g.limits.MaxAttemptsPerLogin, details: "megaline login"},
+	}
+
+	now := time.Now()
+	for _, s := range subjects {
+		attempt, err := g.loginAttemptStorage.Get(ctx, s.subject)
+		if errors.Is(err, storage.ErrNotFound) {
+			attempt, err = &model.LoginAttempt{Subject: s.subject, WindowStart: now}, nil
+		}
+
+		if err != nil {
+			log.Error("get login attempts", "error", err)
+			return fmt.Errorf("get login attempts: %w", err)
+		}
+
+		// Start a new window when the previous one is over
+		if now.Sub(attempt.WindowStart) > g.limits.Window {
+			attempt.Failures, attempt.WindowStart = 0, now
+		}
+
+		attempt.Failures++
+		if attempt.Failures >= s.maxAttempts && !attempt.IsLocked(now) {
+			attempt.LockedUntil = now.Add(g.limits.Lockout)
+			attempt.Failures, attempt.WindowStart = 0, attempt.LockedUntil
+
+			log.Warn("Login locked out", "subject", s.details, "until", attempt.LockedUntil)
+			_ = g.auditor.Record(ctx, model.AuditActionLoginLocked, userID, s.details)
+		}
+
+		if err = g.loginAttemptStorage.Save(ctx, attempt); err != nil {
+			log.Error("save login attempts", "error", err)
+			return fmt.Errorf("save login attempts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Succeed resets the failed attempts of the user and the MegaLine login.
+func (g *LoginGuard) Succeed(ctx context.Context, userID int64, login string) error {
+	if _, err := g.loginAttemptStorage.Delete(ctx, g.userSubject(userID), g.loginSubject(login)); err != nil {
+		g.logger.Error("delete login attempts", "error", err)
+		return fmt.Errorf("delete login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock clears the lockouts of the target, which is either a Telegram ID or a MegaLine login. As the MegaLine
+// logins can be numeric as well, a number clears both. It returns the number of cleared subjects.
+func (g *LoginGuard) Unlock(ctx context.Context, adminID int64, target string) (int64, error) {
+	subjects := []string{g.loginSubject(target)}
+	if userID, err := strconv.ParseInt(target, 10, 64); err == nil {
+		subjects = append(subjects, g.userSubject(userID))
+	}
+
+	cleared, err := g.loginAttemptStorage.Delete(ctx, subjects...)
+	if err != nil {
+		g.logger.Error("delete login attempts", "error", err)
+		return 0, fmt.Errorf("delete login attempts: %w", err)
+	}
+
+	_ = g.auditor.Record(ctx, model.AuditActionAdminLoginUnlocked, adminID, fmt.Sprintf("cleared %d", cleared))
+	return cleared, nil
+}
+
+func (g *LoginGuard) userSubject(userID int64) string {
+	return "tg:" + keyedHash(g.salt, strconv.FormatInt(userID, 10))
+}
+
+func (g *LoginGuard) loginSubject(login string) string {
+	return "login:" + keyedHash(g.salt, login)
+}
@@ -0,0 +1,246 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const (
+	dispatchInterval  = 15 * time.Second
+	dispatchBatchSize = 50
+	// The failed deliveries are retried after retryBackoff doubled with every attempt up to maxRetryBackoff, and
+	// given up after maxDeliveryAttempts.
+	retryBackoff        = 30 * time.Second
+	maxRetryBackoff     = 6 * time.Hour
+	maxDeliveryAttempts = 10
+	// The sent and the given up notifications are removed after notificationRetention, the payloads are needed
+	// only for the delivery.
+	notificationRetention = 30 * 24 * time.Hour
+)
+
+type notificationStorage interface {
+	ListDue(ctx context.Context, now time.Time, limit int) ([]model.Notification, error)
+	MarkSent(ctx context.Context, id int, attempts int, sentAt time.Time) error
+	MarkRetry(ctx context.Context, id int, attempts int, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(ctx context.Context, id int, attempts int, lastError string) error
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// notifier delivers the notifications to the users.
+type notifier interface {
+	NotifyPaymentDue(ctx context.Context, userID int64, account model.Account, daysLeft int, topUp *TopUp) error
+	NotifyStatusChanged(ctx context.Context, userID int64, account model.Account, previous model.AccountStatus) error
+	NotifyPeriodDigest(ctx context.Context, userID int64, digest PeriodDigest) error
+	NotifyChargeAnomaly(ctx context.Context, userID int64, anomaly ChargeAnomaly) error
+	NotifyTariffChanged(ctx context.Context, userID int64, change TariffChange) error
+}
+
+// AccountSummary is the part of the account the notifications tell about. The payloads keep it instead of the
+// account, so the personal data of the account information stays out of the outbox.
+type AccountSummary struct {
+	Number       string
+	Billing      model.BillingPeriod
+	TariffAmount model.Money
+	TariffName   string
+	Balance      model.Money
+	Status       model.AccountStatus
+}
+
+func summarizeAccount(account model.Account) AccountSummary {
+	return AccountSummary{
+		Number:       account.Number,
+		Billing:      account.Billing,
+		TariffAmount: account.TariffAmount,
+		TariffName:   account.TariffName,
+		Balance:      account.Balance,
+		Status:       account.Status,
+	}
+}
+
+// Account returns the account with the fields of the summary only.
+func (s AccountSummary) Account() model.Account {
+	return model.Account{
+		Number:       s.Number,
+		Billing:      s.Billing,
+		TariffAmount: s.TariffAmount,
+		TariffName:   s.TariffName,
+		Balance:      s.Balance,
+		Status:       s.Status,
+	}
+}
+
+// PaymentDue is the payload of the payment reminder.
+type PaymentDue struct {
+	Account  AccountSummary `json:"account"`
+	DaysLeft int            `json:"days_left"`
+}
+
+// StatusChange is the payload of the notification about the blocked or restored account.
+type StatusChange struct {
+	Account  AccountSummary      `json:"account"`
+	Previous model.AccountStatus `json:"previous"`
+}
+
+// NotificationUseCase delivers the notifications enqueued to the Outbox with RunDispatch, with the retries. The
+// delivery is at least once: the notification sent right before the process stops may be sent again after
+// the restart.
+type NotificationUseCase struct {
+	logger   *slog.Logger
+	storage  notificationStorage
+	topUps   topUpGenerator
+	notifier notifier
+}
+
+func NewNotificationUseCase(logger *slog.Logger, storage notificationStorage, topUps topUpGenerator, notifier notifier) *NotificationUseCase {
+	return &NotificationUseCase{
+		logger:   logger.With("use_case", "NotificationUseCase"),
+		storage:  storage,
+		topUps:   topUps,
+		notifier: notifier,
+	}
+}
+
+// RunDispatch delivers the due notifications until the context is canceled. The notifications left pending by
+// the previous run are delivered right away.
+func (uc *NotificationUseCase) RunDispatch(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		uc.dispatch(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunRetention removes the finished notifications past the retention once a day until the context is canceled.
+func (uc *NotificationUseCase) RunRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		uc.purge(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (uc *NotificationUseCase) purge(ctx context.Context, now time.Time) {
+	log := uc.logger.With("method", "purge")
+
+	deleted, err := uc.storage.DeleteFinishedBefore(ctx, now.Add(-notificationRetention))
+	if err != nil {
+		log.Error("delete finished notifications", "error", err)
+		return
+	}
+
+	log.Info("Deleted finished notifications", "deleted", deleted)
+}
+
+func (uc *NotificationUseCase) dispatch(ctx context.Context, now time.Time) {
+	log := uc.logger.With("method", "dispatch")
+
+	notifications, err := uc.storage.ListDue(ctx, now, dispatchBatchSize)
+	if err != nil {
+		log.Error("list due notifications", "error", err)
+		return
+	}
+
+	for _, notification := range notifications {
+		if ctx.Err() != nil {
+			return
+		}
+
+		log := log.With("notification_id", notification.ID, "kind", notification.Kind, "user_id", notification.UserID)
+		attempts := notification.Attempts + 1
+
+		deliveryErr := uc.deliver(ctx, notification)
+		switch {
+		case deliveryErr == nil:
+			err = uc.storage.MarkSent(ctx, notification.ID, attempts, time.Now())
+		case attempts >= maxDeliveryAttempts:
+			log.Error("give up notification", "error", deliveryErr, "attempts", attempts)
+			err = uc.storage.MarkFailed(ctx, notification.ID, attempts, deliveryErr.Error())
+		default:
+			log.Warn("retry notification", "error", deliveryErr, "attempts", attempts)
+			err = uc.storage.MarkRetry(ctx, notification.ID, attempts, now.Add(retryDelay(attempts)), deliveryErr.Error())
+		}
+
+		if err != nil {
+			log.Error("update notification status", "error", err)
+		}
+	}
+}
+
+// deliver sends the notification with the payload of its kind.
+func (uc *NotificationUseCase) deliver(ctx context.Context, notification model.Notification) error {
+	switch notification.Kind {
+	case model.NotificationKindPaymentDue:
+		var payload PaymentDue
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+
+		account := payload.Account.Account()
+
+		topUp, err := uc.topUps.TopUp(account)
+		if err != nil {
+			return fmt.Errorf("prepare top-up: %w", err)
+		}
+
+		return uc.notifier.NotifyPaymentDue(ctx, notification.UserID, account, payload.DaysLeft, topUp)
+	case model.NotificationKindStatusChanged:
+		var payload StatusChange
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+
+		return uc.notifier.NotifyStatusChanged(ctx, notification.UserID, payload.Account.Account(), payload.Previous)
+	case model.NotificationKindPeriodDigest:
+		var payload PeriodDigest
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+
+		return uc.notifier.NotifyPeriodDigest(ctx, notification.UserID, payload)
+	case model.NotificationKindChargeAnomaly:
+		var payload ChargeAnomaly
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+
+		return uc.notifier.NotifyChargeAnomaly(ctx, notification.UserID, payload)
+	case model.NotificationKindTariffChanged:
+		var payload TariffChange
+		if err := json.Unmarshal(notification.Payload, &payload); err != nil {
+			return fmt.Errorf("unmarshal payload: %w", err)
+		}
+
+		return uc.notifier.NotifyTariffChanged(ctx, notification.UserID, payload)
+	default:
+		return fmt.Errorf("unknown notification kind %q", notification.Kind)
+	}
+}
+
+// retryDelay returns the delay before the next attempt after the failed attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := retryBackoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryBackoff)
+}
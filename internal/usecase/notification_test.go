@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestNotificationPayloadsOmitAccountInfo(t *testing.T) {
+	account := model.Account{
+		ID:           1,
+		Number:       "996555000001",
+		Balance:      model.Som(100),
+		TariffAmount: model.Som(950),
+		Status:       model.AccountStatusBlocked,
+		Info:         model.AccountInfo{{Key: "Абонент", Value: "Иванов Иван"}},
+	}
+
+	payloads := map[string]any{
+		"payment due":   PaymentDue{Account: summarizeAccount(account), DaysLeft: 3},
+		"status change": StatusChange{Account: summarizeAccount(account), Previous: model.AccountStatusActive},
+		"period digest": PeriodDigest{Account: summarizeAccount(account)},
+		"anomaly":       ChargeAnomaly{Account: summarizeAccount(account)},
+		"tariff change": TariffChange{Account: summarizeAccount(account)},
+	}
+
+	for name, payload := range payloads {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				t.Fatalf("marshal payload: %v", err)
+			}
+
+			if strings.Contains(string(data), "Иванов") || strings.Contains(string(data), "Info") {
+				t.Errorf("payload %s contains the account information", data)
+			}
+
+			if !strings.Contains(string(data), account.Number) {
+				t.Errorf("payload %s lacks the account number", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/aastashov/megalinekg_bot/internal/event"
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+)
+
+// SubscribeMetrics counts the outcomes of the refreshes published on the bus. The events dropped by the bus are not
+// counted, the metrics show the trend rather than the exact numbers.
+func SubscribeMetrics(bus *event.Bus) {
+	event.Subscribe(bus, "metrics", func(ctx context.Context, e event.AccountDiscovered) {
+		metrics.AccountsDiscovered.Inc()
+	})
+
+	event.Subscribe(bus, "metrics", func(ctx context.Context, e event.PaymentDetected) {
+		metrics.PaymentsDetected.Inc()
+	})
+
+	event.Subscribe(bus, "metrics", func(ctx context.Context, e event.StatusChanged) {
+		metrics.StatusChanges.WithLabelValues(string(e.Account.Status)).Inc()
+	})
+
+	event.Subscribe(bus, "metrics", func(ctx context.Context, e event.LoginFailed) {
+		metrics.LoginFailures.WithLabelValues(e.Reason).Inc()
+	})
+}
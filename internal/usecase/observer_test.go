@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/aastashov/megalinekg_bot/internal/event"
+	"github.com/aastashov/megalinekg_bot/internal/metrics"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestSubscribeMetrics(t *testing.T) {
+	payments := testutil.ToFloat64(metrics.PaymentsDetected)
+	blocked := testutil.ToFloat64(metrics.StatusChanges.WithLabelValues(string(model.AccountStatusBlocked)))
+	failures := testutil.ToFloat64(metrics.LoginFailures.WithLabelValues("bad credentials"))
+
+	bus := event.NewBus(discardLogger)
+	SubscribeMetrics(bus)
+
+	ctx := context.Background()
+	bus.Publish(ctx, event.PaymentDetected{UserID: 100})
+	bus.Publish(ctx, event.PaymentDetected{UserID: 100})
+	bus.Publish(ctx, event.StatusChanged{UserID: 100, Account: model.Account{Status: model.AccountStatusBlocked}})
+	bus.Publish(ctx, event.LoginFailed{UserID: 100, Reason: "bad credentials"})
+	bus.Close()
+
+	if got := testutil.ToFloat64(metrics.PaymentsDetected) - payments; got != 2 {
+		t.Errorf("payments detected = %v, want 2", got)
+	}
+
+	if got := testutil.ToFloat64(metrics.StatusChanges.WithLabelValues(string(model.AccountStatusBlocked))) - blocked; got != 1 {
+		t.Errorf("status changes to blocked = %v, want 1", got)
+	}
+
+	if got := testutil.ToFloat64(metrics.LoginFailures.WithLabelValues("bad credentials")) - failures; got != 1 {
+		t.Errorf("login failures = %v, want 1", got)
+	}
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+type outboxStorage interface {
+	Enqueue(ctx context.Context, notification *model.Notification) (bool, error)
+}
+
+// outbox stores the notifications to deliver them later.
+type outbox interface {
+	Enqueue(ctx context.Context, userID int64, kind model.NotificationKind, key string, payload any) error
+}
+
+// Outbox stores the outgoing notifications in the transaction of the state change they tell about, they are
+// delivered by NotificationUseCase. It needs nothing but the storage, so the use cases noticing the changes don't
+// depend on the delivery.
+type Outbox struct {
+	storage outboxStorage
+}
+
+func NewOutbox(storage outboxStorage) *Outbox {
+	return &Outbox{
+		storage: storage,
+	}
+}
+
+// Enqueue stores the notification of the user with the payload of the kind. The notification with the key that is
+// enqueued already is ignored, so the same change is never told twice. The notification takes part in
+// the transaction of the context.
+func (uc *Outbox) Enqueue(ctx context.Context, userID int64, kind model.NotificationKind, key string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	notification := &model.Notification{
+		UserID:         userID,
+		Kind:           kind,
+		IdempotencyKey: key,
+		Payload:        data,
+		Status:         model.NotificationStatusPending,
+		NextAttemptAt:  time.Now(),
+	}
+
+	if _, err = uc.storage.Enqueue(ctx, notification); err != nil {
+		return fmt.Errorf("enqueue notification: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const maskedSecret = "********"
+
+type transactor interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type privacyUserStorage interface {
+	GetByTelegramID(ctx context.Context, userID int64) (*model.User, error)
+	DeleteByTelegramID(ctx context.Context, userID int64) error
+}
+
+type privacySnapshotStorage interface {
+	ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.BalanceSnapshot, error)
+}
+
+type privacyPaymentStorage interface {
+	ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.Payment, error)
+}
+
+type privacyNotificationStorage interface {
+	ListByUserID(ctx context.Context, userID int64) ([]model.Notification, error)
+}
+
+// Export is the document with all the data stored about the user.
+type Export struct {
+	ExportedAt    time.Time            `json:"exported_at"`
+	User          ExportUser           `json:"user"`
+	Accounts      []ExportAccount      `json:"accounts"`
+	Notifications []ExportNotification `json:"notifications"`
+}
+
+type ExportUser struct {
+	ID           int    `json:"id"`
+	TelegramID   int64  `json:"telegram_id"`
+	AuthUsername string `json:"auth_username"`
+	AuthPassword string `json:"auth_password"`
+	Session      string `json:"session"`
+	// CalendarFeed is whether the calendar feed is issued, the token itself is stored only as a hash.
+	CalendarFeed bool `json:"calendar_feed"`
+}
+
+type ExportAccount struct {
+	ID                 int                      `json:"id"`
+	Number             string                   `json:"number"`
+	BillingFrom        time.Time                `json:"billing_from"`
+	BillingTo          time.Time                `json:"billing_to"`
+	TariffAmount       model.Money              `json:"tariff_amount"`
+	TariffName         string                   `json:"tariff_name"`
+	Balance            model.Money              `json:"balance"`
+	Status             model.AccountStatus      `json:"status"`
+	Info               model.AccountInfo        `json:"info"`
+	RemindedFor        time.Time                `json:"reminded_for"`
+	DigestEnabled      bool                     `json:"digest_enabled"`
+	AnomalySensitivity model.AnomalySensitivity `json:"anomaly_sensitivity"`
+	History            []ExportBalanceRecord    `json:"history"`
+	Payments           []ExportPayment          `json:"payments"`
+}
+
+type ExportBalanceRecord struct {
+	RecordedAt   time.Time   `json:"recorded_at"`
+	Balance      model.Money `json:"balance"`
+	TariffAmount model.Money `json:"tariff_amount"`
+	TariffName   string      `json:"tariff_name"`
+	BillingFrom  time.Time   `json:"billing_from"`
+	BillingTo    time.Time   `json:"billing_to"`
+}
+
+type ExportPayment struct {
+	PaidAt time.Time   `json:"paid_at"`
+	Amount model.Money `json:"amount"`
+	Source string      `json:"source"`
+}
+
+// ExportNotification is the notification in the outbox, the queued ones and the delivered ones not yet removed.
+type ExportNotification struct {
+	Kind          model.NotificationKind   `json:"kind"`
+	Status        model.NotificationStatus `json:"status"`
+	Payload       json.RawMessage          `json:"payload"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt time.Time                `json:"next_attempt_at"`
+	LastError     string                   `json:"last_error"`
+	SentAt        *time.Time               `json:"sent_at"`
+	CreatedAt     time.Time                `json:"created_at"`
+}
+
+type PrivacyUseCase struct {
+	logger              *slog.Logger
+	transactor          transactor
+	userStorage         privacyUserStorage
+	snapshotStorage     privacySnapshotStorage
+	paymentStorage      privacyPaymentStorage
+	notificationStorage privacyNotificationStorage
+	auditor             auditor
+}
+
+func NewPrivacyUseCase(logger *slog.Logger, transactor transactor, userStorage privacyUserStorage, snapshotStorage privacySnapshotStorage, paymentStorage privacyPaymentStorage, notificationStorage privacyNotificationStorage, auditor auditor) *PrivacyUseCase {
+	return &PrivacyUseCase{
+		logger:              logger.With("use_case", "PrivacyUseCase"),
+		transactor:          transactor,
+		userStorage:         userStorage,
+		snapshotStorage:     snapshotStorage,
+		paymentStorage:      paymentStorage,
+		notificationStorage: notificationStorage,
+		auditor:             auditor,
+	}
+}
+
+// Export collects everything stored about the user. The password and the session are masked, they are credentials
+// and must not leave the bot even to their owner.
+func (uc *PrivacyUseCase) Export(ctx context.Context, userID int64) (*Export, error) {
+	log := uc.logger.With("method", "Export", "user_id", userID)
+
+	user, err := uc.userStorage.GetByTelegramID(ctx, userID)
+	if err != nil {
+		log.Error("get user by telegram ID", "error", err)
+		return nil, fmt.Errorf("get user by telegram ID: %w", err)
+	}
+
+	accountIDs := make([]int, 0, len(user.Accounts))
+	for _, account := range user.Accounts {
+		accountIDs = append(accountIDs, account.ID)
+	}
+
+	snapshots, err := uc.snapshotStorage.ListByAccountIDs(ctx, accountIDs)
+	if err != nil {
+		log.Error("list balance snapshots", "error", err)
+		return nil, fmt.Errorf("list balance snapshots: %w", err)
+	}
+
+	history := make(map[int][]ExportBalanceRecord, len(user.Accounts))
+	for _, snapshot := range snapshots {
+		history[snapshot.AccountID] = append(history[snapshot.AccountID], ExportBalanceRecord{
+			RecordedAt:   snapshot.CreatedAt,
+			Balance:      snapshot.Balance,
+			TariffAmount: snapshot.TariffAmount,
+			TariffName:   snapshot.TariffName,
+			BillingFrom:  snapshot.Billing.From,
+			BillingTo:    snapshot.Billing.To,
+		})
+	}
+
+	storedPayments, err := uc.paymentStorage.ListByAccountIDs(ctx, accountIDs)
+	if err != nil {
+		log.Error("list payments", "error", err)
+		return nil, fmt.Errorf("list payments: %w", err)
+	}
+
+	payments := make(map[int][]ExportPayment, len(user.Accounts))
+	for _, payment := range storedPayments {
+		payments[payment.AccountID] = append(payments[payment.AccountID], ExportPayment{
+			PaidAt: payment.PaidAt,
+			Amount: payment.Amount,
+			Source: payment.Source,
+		})
+	}
+
+	notifications, err := uc.notificationStorage.ListByUserID(ctx, userID)
+	if err != nil {
+		log.Error("list notifications", "error", err)
+		return nil, fmt.Errorf("list notifications: %w", err)
+	}
+
+	export := &Export{
+		ExportedAt: time.Now(),
+		User: ExportUser{
+			ID:           user.ID,
+			TelegramID:   user.TelegramID,
+			AuthUsername: user.AuthUsername,
+			AuthPassword: mask(user.AuthPassword),
+			Session:      mask(user.Session),
+			CalendarFeed: user.CalendarTokenHash != nil,
+		},
+		Accounts:      make([]ExportAccount, 0, len(user.Accounts)),
+		Notifications: make([]ExportNotification, 0, len(notifications)),
+	}
+
+	for _, account := range user.Accounts {
+		export.Accounts = append(export.Accounts, ExportAccount{
+			ID:                 account.ID,
+			Number:             account.Number,
+			BillingFrom:        account.Billing.From,
+			BillingTo:          account.Billing.To,
+			TariffAmount:       account.TariffAmount,
+			TariffName:         account.TariffName,
+			Balance:            account.Balance,
+			Status:             account.Status,
+			Info:               account.Info,
+			RemindedFor:        account.RemindedFor,
+			DigestEnabled:      account.DigestEnabled,
+			AnomalySensitivity: account.AnomalySensitivity,
+			History:            history[account.ID],
+			Payments:           payments[account.ID],
+		})
+	}
+
+	for _, notification := range notifications {
+		exported := ExportNotification{
+			Kind:          notification.Kind,
+			Status:        notification.Status,
+			Attempts:      notification.Attempts,
+			NextAttemptAt: notification.NextAttemptAt,
+			LastError:     notification.LastError,
+			SentAt:        notification.SentAt,
+			CreatedAt:     notification.CreatedAt,
+		}
+
+		if len(notification.Payload) > 0 {
+			exported.Payload = notification.Payload
+		}
+
+		export.Notifications = append(export.Notifications, exported)
+	}
+
+	if err = uc.auditor.Record(ctx, model.AuditActionDataExported, userID, ""); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// DeleteUser wipes the user together with the saved credentials, the session, the accounts and their history.
+// The deletion and the audit record proving it happened are committed in the same transaction.
+func (uc *PrivacyUseCase) DeleteUser(ctx context.Context, userID int64) error {
+	log := uc.logger.With("method", "DeleteUser", "user_id", userID)
+
+	err := uc.transactor.InTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.userStorage.DeleteByTelegramID(ctx, userID); err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+
+		return uc.auditor.Record(ctx, model.AuditActionUserDeleted, userID, "")
+	})
+
+	if err != nil {
+		log.Error("delete user", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return maskedSecret
+}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// exportedFields maps every field of the stored models to the keys of the export it is exported as. The fields
+// deliberately left out map to no keys. A field added to the model fails TestExportCoversStoredFields until it is
+// either exported or listed here as left out.
+var exportedFields = []struct {
+	model  any
+	export any
+	fields map[string][]string
+}{
+	{
+		model:  model.User{},
+		export: ExportUser{},
+		fields: map[string][]string{
+			"ID":                {"id"},
+			"TelegramID":        {"telegram_id"},
+			"AuthUsername":      {"auth_username"},
+			"AuthPassword":      {"auth_password"},
+			"Session":           {"session"},
+			"CalendarTokenHash": {"calendar_feed"},
+			// The accounts are exported next to the user
+			"Accounts": nil,
+		},
+	},
+	{
+		model:  model.Account{},
+		export: ExportAccount{},
+		fields: map[string][]string{
+			"ID":                 {"id"},
+			"UserID":             nil,
+			"Number":             {"number"},
+			"Billing":            {"billing_from", "billing_to"},
+			"TariffAmount":       {"tariff_amount"},
+			"Balance":            {"balance"},
+			"Status":             {"status"},
+			"TariffName":         {"tariff_name"},
+			"Info":               {"info"},
+			"RemindedFor":        {"reminded_for"},
+			"DigestEnabled":      {"digest_enabled"},
+			"AnomalySensitivity": {"anomaly_sensitivity"},
+		},
+	},
+	{
+		model:  model.BalanceSnapshot{},
+		export: ExportBalanceRecord{},
+		fields: map[string][]string{
+			"ID":           nil,
+			"AccountID":    nil,
+			"Balance":      {"balance"},
+			"TariffAmount": {"tariff_amount"},
+			"Billing":      {"billing_from", "billing_to"},
+			"CreatedAt":    {"recorded_at"},
+			"TariffName":   {"tariff_name"},
+		},
+	},
+	{
+		model:  model.Payment{},
+		export: ExportPayment{},
+		fields: map[string][]string{
+			"ID":        nil,
+			"AccountID": nil,
+			"PaidAt":    {"paid_at"},
+			"Amount":    {"amount"},
+			"Source":    {"source"},
+			// The fingerprint is derived from the exported fields
+			"Fingerprint": nil,
+			"CreatedAt":   nil,
+		},
+	},
+	{
+		model:  model.Notification{},
+		export: ExportNotification{},
+		fields: map[string][]string{
+			"ID":     nil,
+			"UserID": nil,
+			"Kind":   {"kind"},
+			// The idempotency key is made of the kind and the account number
+			"IdempotencyKey": nil,
+			"Payload":        {"payload"},
+			"Status":         {"status"},
+			"Attempts":       {"attempts"},
+			"NextAttemptAt":  {"next_attempt_at"},
+			"LastError":      {"last_error"},
+			"SentAt":         {"sent_at"},
+			"CreatedAt":      {"created_at"},
+			"UpdatedAt":      nil,
+		},
+	},
+}
+
+func TestExportCoversStoredFields(t *testing.T) {
+	for _, tt := range exportedFields {
+		modelType := reflect.TypeOf(tt.model)
+		t.Run(modelType.Name(), func(t *testing.T) {
+			keys := make(map[string]bool)
+			exportType := reflect.TypeOf(tt.export)
+			for i := 0; i < exportType.NumField(); i++ {
+				key, _, _ := strings.Cut(exportType.Field(i).Tag.Get("json"), ",")
+				keys[key] = true
+			}
+
+			for i := 0; i < modelType.NumField(); i++ {
+				name := modelType.Field(i).Name
+				exportedAs, ok := tt.fields[name]
+				if !ok {
+					t.Errorf("%s.%s is neither exported nor listed as left out of the export", modelType.Name(), name)
+				}
+
+				for _, key := range exportedAs {
+					if !keys[key] {
+						t.Errorf("%s.%s is exported as %q, %s has no such key", modelType.Name(), name, key, exportType.Name())
+					}
+				}
+			}
+		})
+	}
+}
+
+type fakePrivacyUserStorage struct {
+	user *model.User
+}
+
+func (s *fakePrivacyUserStorage) GetByTelegramID(ctx context.Context, userID int64) (*model.User, error) {
+	return s.user, nil
+}
+
+func (s *fakePrivacyUserStorage) DeleteByTelegramID(ctx context.Context, userID int64) error {
+	return nil
+}
+
+type fakePrivacySnapshotStorage struct{}
+
+func (fakePrivacySnapshotStorage) ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.BalanceSnapshot, error) {
+	return nil, nil
+}
+
+type fakePrivacyPaymentStorage struct{}
+
+func (fakePrivacyPaymentStorage) ListByAccountIDs(ctx context.Context, accountIDs []int) ([]model.Payment, error) {
+	return nil, nil
+}
+
+type fakePrivacyNotificationStorage struct {
+	notifications []model.Notification
+}
+
+func (s *fakePrivacyNotificationStorage) ListByUserID(ctx context.Context, userID int64) ([]model.Notification, error) {
+	return s.notifications, nil
+}
+
+func TestExport(t *testing.T) {
+	tokenHash := "hash"
+	remindedFor := time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)
+	user := &model.User{
+		ID:                1,
+		TelegramID:        100,
+		AuthUsername:      "0555000001",
+		AuthPassword:      "secret",
+		CalendarTokenHash: &tokenHash,
+		Accounts: []model.Account{{
+			ID:                 1,
+			Number:             "996555000001",
+			RemindedFor:        remindedFor,
+			DigestEnabled:      true,
+			AnomalySensitivity: model.AnomalySensitivityHigh,
+		}},
+	}
+	notifications := &fakePrivacyNotificationStorage{notifications: []model.Notification{
+		{UserID: 100, Kind: model.NotificationKindPaymentDue, Payload: []byte(`{"days_left":3}`), Status: model.NotificationStatusPending},
+		{UserID: 100, Kind: model.NotificationKindStatusChanged, Status: model.NotificationStatusFailed, LastError: "forbidden"},
+	}}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	uc := NewPrivacyUseCase(logger, &fakeTransactor{}, &fakePrivacyUserStorage{user: user}, fakePrivacySnapshotStorage{}, fakePrivacyPaymentStorage{}, notifications, fakeAuditor{})
+
+	export, err := uc.Export(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if !export.User.CalendarFeed || export.User.AuthPassword != maskedSecret {
+		t.Errorf("user = %+v, want the calendar feed issued and the password masked", export.User)
+	}
+
+	account := export.Accounts[0]
+	if !account.RemindedFor.Equal(remindedFor) || !account.DigestEnabled || account.AnomalySensitivity != model.AnomalySensitivityHigh {
+		t.Errorf("account = %+v, want the settings of the account", account)
+	}
+
+	if len(export.Notifications) != 2 {
+		t.Fatalf("notifications = %+v, want both notifications", export.Notifications)
+	}
+
+	if string(export.Notifications[0].Payload) != `{"days_left":3}` || export.Notifications[1].Payload != nil {
+		t.Errorf("payloads = %s, %s, want the stored payload and null", export.Notifications[0].Payload, export.Notifications[1].Payload)
+	}
+
+	if export.Notifications[1].LastError != "forbidden" {
+		t.Errorf("last error = %q, want %q", export.Notifications[1].LastError, "forbidden")
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+var (
+	ErrNoSession       = errors.New("no MegaLine session")
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+// Profile fetches the subscriber profile of the account of the user from the personal cabinet. The profile is
+// personal data and is not stored, every call fetches it again.
+func (uc *BalanceUseCase) Profile(ctx context.Context, userID int64, accountNumber string) (*model.Profile, error) {
+	log := uc.logger.With("method", "Profile", "user_id", userID)
+
+	session, err := uc.sessionFor(ctx, log, userID, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := uc.megaLine.GetProfile(ctx, session, accountNumber)
+	if err != nil {
+		log.Error("get profile", "error", err)
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+
+	profile, err := megaline.ParseProfile(body)
+	if err != nil {
+		log.Error("parse profile", "error", err)
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+// sessionFor returns the MegaLine session of the user after checking that the account belongs to the user.
+func (uc *BalanceUseCase) sessionFor(ctx context.Context, log *slog.Logger, userID int64, accountNumber string) (string, error) {
+	user, _, err := uc.userStorage.GetOrCreateByTelegramID(ctx, userID)
+	if err != nil {
+		log.Error("get user by telegram ID", "error", err)
+		return "", fmt.Errorf("get user by telegram ID: %w", err)
+	}
+
+	owned := slices.ContainsFunc(user.Accounts, func(account model.Account) bool {
+		return account.Number == accountNumber
+	})
+
+	if !owned {
+		return "", ErrAccountNotFound
+	}
+
+	if user.Session == "" {
+		return "", ErrNoSession
+	}
+
+	return user.Session, nil
+}
+
+// accountOf returns the account of the user with the number, ErrAccountNotFound if the user has no such account.
+func (uc *BalanceUseCase) accountOf(ctx context.Context, log *slog.Logger, userID int64, accountNumber string) (*model.Account, error) {
+	user, _, err := uc.userStorage.GetOrCreateByTelegramID(ctx, userID)
+	if err != nil {
+		log.Error("get user by telegram ID", "error", err)
+		return nil, fmt.Errorf("get user by telegram ID: %w", err)
+	}
+
+	for i := range user.Accounts {
+		if user.Accounts[i].Number == accountNumber {
+			return &user.Accounts[i], nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// RequestPromisedPayment requests the promised payment for the account of the user. The outcome is returned as
+// the status, the error is returned only when the request could not be made.
+func (uc *BalanceUseCase) RequestPromisedPayment(ctx context.Context, userID int64, accountNumber string) (megaline.PromisedPaymentStatus, error) {
+	log := uc.logger.With("method", "RequestPromisedPayment", "user_id", userID)
+
+	session, err := uc.sessionFor(ctx, log, userID, accountNumber)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := uc.megaLine.RequestPromisedPayment(ctx, session, accountNumber)
+	if err != nil {
+		log.Error("request promised payment", "error", err)
+		return "", fmt.Errorf("request promised payment: %w", err)
+	}
+
+	log.Info("promised payment requested", "status", status)
+	_ = uc.auditor.Record(ctx, model.AuditActionPromisedPayment, userID, string(status))
+
+	return status, nil
+}
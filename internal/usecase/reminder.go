@@ -0,0 +1,118 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const (
+	reminderInterval = time.Hour
+	// The reminders are sent only in the daytime of the billing location.
+	reminderFromHour = 9
+	reminderToHour   = 21
+)
+
+type reminderUserStorage interface {
+	ListWithAccounts(ctx context.Context) ([]model.User, error)
+}
+
+type reminderAccountStorage interface {
+	SetRemindedFor(ctx context.Context, accountID int, periodEnd time.Time) error
+}
+
+type topUpGenerator interface {
+	TopUp(account model.Account) (*TopUp, error)
+}
+
+// ReminderUseCase reminds the users to top up the accounts whose balance does not cover the tariff of the next
+// billing period. A single reminder is sent per period.
+type ReminderUseCase struct {
+	logger         *slog.Logger
+	daysBefore     int
+	location       *time.Location
+	transactor     transactor
+	userStorage    reminderUserStorage
+	accountStorage reminderAccountStorage
+	outbox         outbox
+}
+
+func NewReminderUseCase(logger *slog.Logger, daysBefore int, location *time.Location, transactor transactor, userStorage reminderUserStorage, accountStorage reminderAccountStorage, outbox outbox) *ReminderUseCase {
+	return &ReminderUseCase{
+		logger:         logger.With("use_case", "ReminderUseCase"),
+		daysBefore:     daysBefore,
+		location:       location,
+		transactor:     transactor,
+		userStorage:    userStorage,
+		accountStorage: accountStorage,
+		outbox:         outbox,
+	}
+}
+
+// RunReminders checks the accounts every hour until the context is canceled. Zero days before the end of the
+// period disables the reminders.
+func (uc *ReminderUseCase) RunReminders(ctx context.Context) {
+	if uc.daysBefore <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		if hour := time.Now().In(uc.location).Hour(); hour >= reminderFromHour && hour < reminderToHour {
+			uc.remind(ctx, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (uc *ReminderUseCase) remind(ctx context.Context, now time.Time) {
+	log := uc.logger.With("method", "remind")
+
+	users, err := uc.userStorage.ListWithAccounts(ctx)
+	if err != nil {
+		log.Error("list users", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		for _, account := range user.Accounts {
+			if err = uc.remindAccount(ctx, now, user.TelegramID, account); err != nil {
+				log.Error("remind about payment", "error", err, "user_id", user.TelegramID, "account", account.Number)
+			}
+		}
+	}
+}
+
+func (uc *ReminderUseCase) remindAccount(ctx context.Context, now time.Time, userID int64, account model.Account) error {
+	billing := account.Billing.In(uc.location)
+	if billing.IsZero() || account.RemindedFor.Equal(billing.To) || account.AmountDue().IsZero() {
+		return nil
+	}
+
+	daysLeft := billing.DaysRemaining(now)
+	if daysLeft == 0 || daysLeft > uc.daysBefore {
+		return nil
+	}
+
+	// The reminder is enqueued together with the mark that it was sent for the period, so that it is sent once
+	account.Billing = billing
+	key := fmt.Sprintf("%s:%d:%s", model.NotificationKindPaymentDue, account.ID, billing.To.Format(time.DateOnly))
+
+	return uc.transactor.InTransaction(ctx, func(ctx context.Context) error {
+		if err := uc.accountStorage.SetRemindedFor(ctx, account.ID, billing.To); err != nil {
+			return fmt.Errorf("set reminded: %w", err)
+		}
+
+		return uc.outbox.Enqueue(ctx, userID, model.NotificationKindPaymentDue, key, PaymentDue{Account: summarizeAccount(account), DaysLeft: daysLeft})
+	})
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+// TariffChange is the change of the tariff amount or name of the account noticed by a refresh.
+type TariffChange struct {
+	// Account is the account with the new tariff.
+	Account        AccountSummary
+	PreviousAmount model.Money
+	PreviousName   string
+	// Before and After are the forecasts of the balance at the previous and the new tariff, Forecasted is false
+	// if there is nothing to forecast from.
+	Before     Forecast
+	After      Forecast
+	Forecasted bool
+}
+
+// isTariffChange reports whether the tariff of the account differs from the previous one. The first tariff seen
+// is not a change, neither is the name that could not be read.
+func isTariffChange(previousAmount model.Money, previousName string, account model.Account) bool {
+	amountChanged := !previousAmount.IsZero() && previousAmount.Cmp(account.TariffAmount) != 0
+	nameChanged := previousName != "" && account.TariffName != "" && previousName != account.TariffName
+
+	return amountChanged || nameChanged
+}
+
+// alertTariffChange tells the owner of the account about the new tariff and its effect on the forecast.
+func (uc *AlertUseCase) alertTariffChange(ctx context.Context, refresh AccountRefresh) error {
+	if !isTariffChange(refresh.PreviousTariff, refresh.PreviousTariffName, refresh.Account) {
+		return nil
+	}
+
+	uc.logger.Info("tariff changed", "method", "alertTariffChange", "user_id", refresh.UserID, "account", refresh.Account.Number,
+		"previous_amount", refresh.PreviousTariff, "tariff_amount", refresh.Account.TariffAmount, "previous_name", refresh.PreviousTariffName, "tariff_name", refresh.Account.TariffName)
+
+	change := TariffChange{Account: summarizeAccount(refresh.Account), PreviousAmount: refresh.PreviousTariff, PreviousName: refresh.PreviousTariffName}
+
+	previous := refresh.Account
+	previous.TariffAmount = refresh.PreviousTariff
+	before, beforeOK := uc.forecaster.Forecast(previous)
+	after, afterOK := uc.forecaster.Forecast(refresh.Account)
+	if beforeOK && afterOK {
+		change.Before, change.After, change.Forecasted = before, after, true
+	}
+
+	key := fmt.Sprintf("%s:%d", model.NotificationKindTariffChanged, refresh.Current.ID)
+	if err := uc.outbox.Enqueue(ctx, refresh.UserID, model.NotificationKindTariffChanged, key, change); err != nil {
+		return fmt.Errorf("enqueue tariff change: %w", err)
+	}
+
+	return nil
+}
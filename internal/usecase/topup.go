@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+const qrCodeSize = 512
+
+// PaymentLink is a link to a payment provider. As a template, {account} and {amount} in the URL are replaced with
+// the account number and the amount due in soms, e.g. "150.50".
+type PaymentLink struct {
+	Name string
+	URL  string
+}
+
+// TopUp is everything needed to top up the account without typing the account number by hand.
+type TopUp struct {
+	AccountNumber string
+	Amount        model.Money
+	// QRCode is the PNG image of the QR code.
+	QRCode []byte
+	Links  []PaymentLink
+}
+
+// TopUpUseCase prepares the top-ups of the accounts. The QR codes are generated locally, the account number is
+// not sent to any third party to get one. The QR template is either the text shown by the scanner, e.g. the
+// default "MegaLine {account} {amount} KGS", or the URL of the payment page of a provider, which is escaped like
+// the links.
+type TopUpUseCase struct {
+	qrTemplate    string
+	linkTemplates []PaymentLink
+}
+
+func NewTopUpUseCase(qrTemplate string, linkTemplates []PaymentLink) *TopUpUseCase {
+	return &TopUpUseCase{
+		qrTemplate:    qrTemplate,
+		linkTemplates: linkTemplates,
+	}
+}
+
+// TopUp returns the top-up of the amount due of the account.
+func (uc *TopUpUseCase) TopUp(account model.Account) (*TopUp, error) {
+	topUp := &TopUp{AccountNumber: account.Number, Amount: account.AmountDue()}
+
+	qrCode, err := qrcode.Encode(fillPaymentTemplate(uc.qrTemplate, topUp, isURLTemplate(uc.qrTemplate)), qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("encode QR code: %w", err)
+	}
+
+	topUp.QRCode = qrCode
+	for _, link := range uc.linkTemplates {
+		topUp.Links = append(topUp.Links, PaymentLink{Name: link.Name, URL: fillPaymentTemplate(link.URL, topUp, true)})
+	}
+
+	return topUp, nil
+}
+
+// fillPaymentTemplate replaces {account} and {amount} in the template, the values are query escaped for the URLs.
+func fillPaymentTemplate(template string, topUp *TopUp, escape bool) string {
+	account, amount := topUp.AccountNumber, topUp.Amount.Decimal()
+	if escape {
+		account, amount = url.QueryEscape(account), url.QueryEscape(amount)
+	}
+
+	return strings.NewReplacer("{account}", account, "{amount}", amount).Replace(template)
+}
+
+// isURLTemplate reports whether the template is a URL the values must be escaped in.
+func isURLTemplate(template string) bool {
+	parsed, err := url.Parse(template)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "http" || parsed.Scheme == "https" || parsed.Scheme == "tg"
+}
@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/aastashov/megalinekg_bot/internal/model"
+)
+
+func TestFillPaymentTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		topUp    TopUp
+		escape   bool
+		want     string
+	}{
+		{
+			name:     "plain text",
+			template: "MegaLine {account} {amount} KGS",
+			topUp:    TopUp{AccountNumber: "996555000001", Amount: model.Tyiyn(15050)},
+			want:     "MegaLine 996555000001 150.50 KGS",
+		},
+		{
+			name:     "plain text is not escaped",
+			template: "{account}/{amount}",
+			topUp:    TopUp{AccountNumber: "12 34&x", Amount: model.Som(5)},
+			want:     "12 34&x/5.00",
+		},
+		{
+			name:     "URL",
+			template: "https://pay.example.kg/megaline?account={account}&amount={amount}",
+			topUp:    TopUp{AccountNumber: "996555000001", Amount: model.Som(950)},
+			escape:   true,
+			want:     "https://pay.example.kg/megaline?account=996555000001&amount=950.00",
+		},
+		{
+			name:     "URL with the query characters in the account",
+			template: "https://pay.example.kg/?account={account}&amount={amount}",
+			topUp:    TopUp{AccountNumber: "12 34&amount=1#x", Amount: model.Tyiyn(1)},
+			escape:   true,
+			want:     "https://pay.example.kg/?account=12+34%26amount%3D1%23x&amount=0.01",
+		},
+		{
+			name:     "repeated placeholders",
+			template: "tg://resolve?domain=pay&start={account}_{account}",
+			topUp:    TopUp{AccountNumber: "a/b"},
+			escape:   true,
+			want:     "tg://resolve?domain=pay&start=a%2Fb_a%2Fb",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fillPaymentTemplate(tt.template, &tt.topUp, tt.escape); got != tt.want {
+				t.Errorf("fillPaymentTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsURLTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     bool
+	}{
+		{template: "MegaLine {account} {amount} KGS", want: false},
+		{template: "https://pay.example.kg/?account={account}", want: true},
+		{template: "http://pay.example.kg/{account}", want: true},
+		{template: "tg://resolve?domain=pay&start={account}", want: true},
+		{template: "{account}:{amount}", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if got := isURLTemplate(tt.template); got != tt.want {
+				t.Errorf("isURLTemplate(%q) = %v, want %v", tt.template, got, tt.want)
+			}
+		})
+	}
+}
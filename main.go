@@ -2,52 +2,66 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log/slog"
-	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"time"
-
-	"github.com/aastashov/megalinekg_bot/config"
-	"github.com/aastashov/megalinekg_bot/internal/interaction/megaline"
-	"github.com/aastashov/megalinekg_bot/internal/interaction/telegram"
-	"github.com/aastashov/megalinekg_bot/internal/storage"
-	"github.com/aastashov/megalinekg_bot/internal/usecase"
+
+	// Embed the timezone database, the runtime image has none
+	_ "time/tzdata"
 )
 
+const usage = `Usage: app [--config path] <command> [arguments]
+
+Commands:
+  serve                  run the Telegram bot (default)
+  migrate                apply the database migrations
+  check-login <user>     log in to MegaLine and print the accounts found, the password is read from stdin
+  refresh --tg-id <id>   refresh the balance of the user and print the accounts
+  parse [--timezone tz] <file.html>
+                         run the account parser on a saved billing page and print JSON
+  export --tg-id <id>    print all the data stored about the user as JSON
+
+Flags:
+`
+
 func main() {
-	baseDir, err := os.Getwd()
-	if err != nil {
-		panic(fmt.Errorf("cannot get current working directory: %w", err))
+	configPath := flag.String("config", "./config.yml", "path to the config file")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
-
-	cnf := config.MustLoad(filepath.Join(baseDir, "./config.yml"))
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cnf.Log.GetLevel()}))
+	flag.Parse()
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	// Initialize database
-	connection := storage.MustNewPostgresDB(logger, cnf.Database.GetConnectionString())
-	defer connection.MustClose()
-
-	connection.MustMigration()
-
-	// Initialize storage
-	userStorage := storage.NewUserStorage(connection.DB)
-	accountStorage := storage.NewAccountStorage(connection.DB)
-
-	// Initialize interaction with MegaLine
-	megaLineConnector := megaline.NewConnector(http.Client{Timeout: cnf.MegaLine.Timeout * time.Second})
-
-	// Initialize use case
-	balanceUseCase := usecase.NewBalanceUseCase(logger, userStorage, accountStorage, megaLineConnector)
+	command, args := "serve", []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
+	}
 
-	// Initialize interaction with Telegram
-	telegramConnector := telegram.NewConnector(logger, cnf.Telegram.Token, userStorage, balanceUseCase)
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(ctx, *configPath)
+	case "migrate":
+		err = runMigrate(*configPath)
+	case "check-login":
+		err = runCheckLogin(ctx, *configPath, args)
+	case "refresh":
+		err = runRefresh(ctx, *configPath, args)
+	case "parse":
+		err = runParse(args)
+	case "export":
+		err = runExport(ctx, *configPath, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	logger.Info("Starting Telegram bot")
-	telegramConnector.Start(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		os.Exit(1)
+	}
 }